@@ -3,12 +3,12 @@ package main
 import (
 	"flag"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
 	"github.com/mrmarble/teledock/internal/docker"
 	"github.com/mrmarble/teledock/internal/telegram"
-	"github.com/mrmarble/teledock/internal/utils"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -20,9 +20,8 @@ var (
 )
 
 var (
-	bot         *telegram.Telegram
-	superAdmins = []int64{}
-	dockr       *docker.Docker
+	bot   *telegram.Telegram
+	dockr *docker.Docker
 )
 
 func init() {
@@ -44,43 +43,26 @@ func init() {
 func main() {
 	log.Info().Str("log_level", zerolog.GlobalLevel().String()).Msg("Starting BOT...")
 
-	//Check required env vars
-
-	for _, envVar := range []string{
-		"TELEDOCK_TOKEN",
-		"TELEDOCK_SUPERADMINS",
-	} {
-		if os.Getenv(envVar) == "" {
-			log.Fatal().Str("envvar", envVar).Msg("missing environment variable")
-		}
-	}
-
-	// Load superadmin list
-	if envsa := os.Getenv("TELEDOCK_SUPERADMINS"); envsa != "" {
-		for _, uidStr := range strings.Split(envsa, ",") {
-			uid, errp := utils.ParseInt64(uidStr)
-
-			if errp != nil {
-				log.Fatal().Err(errp).Msg("failed parsing superadmins list")
-			}
-
-			superAdmins = append(superAdmins, uid)
-		}
+	// Load configuration from env vars
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed loading configuration")
 	}
-	log.Info().Ints64("user_ids", superAdmins).Msg("loaded superadmins")
+	log.Info().Int("users", len(cfg.Users)).Msg("loaded users")
 
 	// Connect to docker
-	var err error
 	dockr, err = docker.NewDocker()
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to docker")
 	}
 
-	// Ping the daemon
-	dockr.Ping()
+	// Ping the daemon, the bot keeps running and reconnects later if it is down
+	if err = dockr.Ping(); err != nil {
+		log.Warn().Err(err).Msg("docker daemon is unreachable")
+	}
 
 	// Create bot
-	bot, err = telegram.NewBot(os.Getenv("TELEDOCK_TOKEN"), *dockr, superAdmins)
+	bot, err = telegram.NewBot(cfg, dockr, compose.NewCLI(cfg.ComposeCommand))
 
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed bot instantiaion")
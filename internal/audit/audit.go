@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultOK is the result of actions that did not fail.
+const ResultOK = "ok"
+
+// Entry is an action taken through the bot.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username,omitempty"`
+	ChatID   int64     `json:"chat_id"`
+	// Kind is "command" or "callback".
+	Kind    string `json:"kind"`
+	Command string `json:"command"`
+	Target  string `json:"target,omitempty"`
+	Args    string `json:"args,omitempty"`
+	// Result is ResultOK or what went wrong.
+	Result string `json:"result"`
+	// Duration is how long the action took, in milliseconds.
+	Duration int64 `json:"duration_ms"`
+}
+
+// String renders the entry in one line.
+func (e Entry) String() string {
+	user := strconv.FormatInt(e.UserID, 10)
+	if e.Username != "" {
+		user = "@" + e.Username
+	}
+	parts := []string{e.Time.Format("2006-01-02 15:04:05"), user, e.Command}
+	if e.Target != "" {
+		parts = append(parts, e.Target)
+	}
+	if e.Args != "" {
+		parts = append(parts, e.Args)
+	}
+	parts = append(parts, e.Result, (time.Duration(e.Duration) * time.Millisecond).String())
+	return strings.Join(parts, " ")
+}
+
+// Matches reports whether the entry was made by user, given by ID or @username.
+func (e Entry) Matches(user string) bool {
+	if user == "" {
+		return true
+	}
+	if strings.HasPrefix(user, "@") {
+		return strings.EqualFold(e.Username, user[1:])
+	}
+	return strconv.FormatInt(e.UserID, 10) == user
+}
+
+// Log is an append only JSONL file of entries.
+type Log struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// Open opens or creates the log at path, and its directory.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed creating audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening audit log: %w", err)
+	}
+	return &Log{path: path, file: file}, nil
+}
+
+// Append writes entry at the end of the log and syncs it to disk.
+func (l *Log) Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Recent returns the last n entries made by user, any user if empty, oldest first.
+func (l *Log) Recent(n int, user string) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || !entry.Matches(user) {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
@@ -0,0 +1,198 @@
+// Package chart renders line charts as PNG images using only the standard library.
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	// yTicks is roughly how many grid lines the vertical axis has.
+	yTicks = 4
+	// xTicks is how many times are labeled on the horizontal axis.
+	xTicks = 5
+	margin = 16
+)
+
+// Colors of the chart, series pick their own.
+var (
+	Blue   = color.RGBA{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff}
+	Orange = color.RGBA{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff}
+
+	background = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	grid       = color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+	axis       = color.RGBA{R: 0x60, G: 0x60, B: 0x60, A: 0xff}
+)
+
+// Point is a value at some time.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a line of a chart.
+type Series struct {
+	Color  color.Color
+	Points []Point
+}
+
+// Chart is a line chart of series over time. The vertical axis starts at zero.
+type Chart struct {
+	Width  int
+	Height int
+	Series []Series
+	// Max is the lowest top of the vertical axis, it grows to fit the values.
+	Max float64
+	// Label formats the values of the vertical axis.
+	Label func(value float64) string
+}
+
+// Empty reports whether no series has the two points needed to draw a line.
+func (c *Chart) Empty() bool {
+	for _, series := range c.Series {
+		if len(series.Points) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Render draws the chart and writes it to w as a PNG image.
+func (c *Chart) Render(w io.Writer) error {
+	img := image.NewRGBA(image.Rect(0, 0, c.Width, c.Height))
+	fillRect(img, 0, 0, c.Width, c.Height, background)
+
+	start, end, top := c.bounds()
+	step := niceStep(top / yTicks)
+	lines := int(math.Ceil(top/step - 1e-9))
+	top = float64(lines) * step
+
+	labelWidth := 0
+	for line := 0; line <= lines; line++ {
+		if width := textWidth(c.Label(float64(line) * step)); width > labelWidth {
+			labelWidth = width
+		}
+	}
+	left, right := margin+labelWidth+8, c.Width-margin-textWidth("00:00")/2
+	upper, lower := margin, c.Height-margin-glyphHeight*fontScale-8
+	span := end.Sub(start)
+
+	x := func(t time.Time) int {
+		return left + int(float64(right-left)*float64(t.Sub(start))/float64(span))
+	}
+	y := func(value float64) int {
+		return lower - int(float64(lower-upper)*value/top)
+	}
+
+	for line := 0; line <= lines; line++ {
+		value := float64(line) * step
+		fillRect(img, left, y(value), right-left, 1, grid)
+		label := c.Label(value)
+		drawText(img, left-8-textWidth(label), y(value)-glyphHeight*fontScale/2, label, axis)
+	}
+	for tick := 0; tick < xTicks; tick++ {
+		at := start.Add(span * time.Duration(tick) / (xTicks - 1))
+		label := at.Format("15:04")
+		fillRect(img, x(at), lower, 1, 4, axis)
+		drawText(img, x(at)-textWidth(label)/2, lower+8, label, axis)
+	}
+	fillRect(img, left, upper, 1, lower-upper, axis)
+	fillRect(img, left, lower, right-left+1, 1, axis)
+
+	for _, series := range c.Series {
+		for index, point := range series.Points {
+			if index == 0 {
+				drawLine(img, x(point.Time), y(point.Value), x(point.Time), y(point.Value), series.Color)
+				continue
+			}
+			previous := series.Points[index-1]
+			drawLine(img, x(previous.Time), y(previous.Value), x(point.Time), y(point.Value), series.Color)
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// bounds returns the time span of the series and the top of the vertical axis.
+func (c *Chart) bounds() (time.Time, time.Time, float64) {
+	var start, end time.Time
+	top := c.Max
+	for _, series := range c.Series {
+		for _, point := range series.Points {
+			if start.IsZero() || point.Time.Before(start) {
+				start = point.Time
+			}
+			if point.Time.After(end) {
+				end = point.Time
+			}
+			if point.Value > top {
+				top = point.Value
+			}
+		}
+	}
+	if !end.After(start) {
+		end = start.Add(time.Minute)
+	}
+	if top <= 0 {
+		top = 1
+	}
+	return start, end, top
+}
+
+// niceStep rounds step up to 1, 2 or 5 times a power of ten.
+func niceStep(step float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, factor := range []float64{1, 2, 5, 10} {
+		if factor*magnitude >= step {
+			return factor * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// drawLine draws a two pixel wide line between two points.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		fillRect(img, x0, y0, 2, 2, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func fillRect(img *image.RGBA, x, y, width, height int, c color.Color) {
+	for row := y; row < y+height; row++ {
+		for column := x; column < x+width; column++ {
+			img.Set(column, row, c)
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
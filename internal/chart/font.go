@@ -0,0 +1,79 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+)
+
+// glyphs is a tiny bitmap font with just the characters axis labels use.
+// Each glyph is 5 rows high and as wide as its rows.
+var glyphs = map[rune][]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'.': {".", ".", ".", ".", "#"},
+	':': {".", "#", ".", "#", "."},
+	'%': {"#.#", "..#", ".#.", "#..", "#.#"},
+	'-': {"...", "...", "###", "...", "..."},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	' ': {"..", "..", "..", "..", ".."},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'G': {"###", "#..", "#.#", "#.#", "###"},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'M': {"#...#", "##.##", "#.#.#", "#...#", "#...#"},
+	'P': {"##.", "#.#", "##.", "#..", "#.."},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'i': {"#", ".", "#", "#", "#"},
+	's': {".##", "#..", ".#.", "..#", "##."},
+}
+
+const (
+	// glyphHeight is the height of every glyph before scaling.
+	glyphHeight = 5
+	// fontScale is how many pixels each dot of a glyph takes on each side.
+	fontScale = 2
+)
+
+// textWidth returns how many pixels text takes once drawn.
+func textWidth(text string) int {
+	width := 0
+	for _, char := range text {
+		if glyph, ok := glyphs[char]; ok {
+			width += (len(glyph[0]) + 1) * fontScale
+		} else {
+			width += 4 * fontScale
+		}
+	}
+	if width > 0 {
+		width -= fontScale
+	}
+	return width
+}
+
+// drawText draws text with its top left corner at x, y. Characters without a glyph are left blank.
+func drawText(img *image.RGBA, x, y int, text string, c color.Color) {
+	for _, char := range text {
+		glyph, ok := glyphs[char]
+		if !ok {
+			x += 4 * fontScale
+			continue
+		}
+		for row, dots := range glyph {
+			for column, dot := range dots {
+				if dot != '#' {
+					continue
+				}
+				fillRect(img, x+column*fontScale, y+row*fontScale, fontScale, fontScale, c)
+			}
+		}
+		x += (len(glyph[0]) + 1) * fontScale
+	}
+}
@@ -0,0 +1,85 @@
+// Package compose runs docker compose commands on the project directories of the config.
+package compose
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/utils"
+)
+
+// ErrTimeout is returned when a command takes longer than its context allows.
+var ErrTimeout = errors.New("timed out")
+
+// Backend runs compose commands on projects. CLI runs them with the compose
+// binary and Fake only records them.
+type Backend interface {
+	// Run runs the compose command args on project, passing every output line to
+	// progress as it is printed. The whole output is returned even if it fails.
+	Run(ctx context.Context, project config.Project, args []string, progress func(line string)) (string, error)
+}
+
+var _ Backend = (*CLI)(nil)
+var _ Backend = (*Fake)(nil)
+
+// CLI runs compose commands with the compose binary.
+type CLI struct {
+	command []string
+}
+
+// NewCLI returns a CLI backend running command, like "docker compose".
+func NewCLI(command []string) *CLI {
+	return &CLI{command: command}
+}
+
+func (c *CLI) Run(ctx context.Context, project config.Project, args []string, progress func(line string)) (string, error) {
+	cmdArgs := append([]string{}, c.command[1:]...)
+	cmdArgs = append(cmdArgs, "--project-name", project.Name, "--project-directory", project.Dir)
+	for _, file := range project.Files {
+		cmdArgs = append(cmdArgs, "--file", filepath.Join(project.Dir, file))
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.CommandContext(ctx, c.command[0], cmdArgs...)
+	cmd.Dir = project.Dir
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("compose %v: %w", strings.Join(args, " "), err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		done <- err
+	}()
+
+	var output strings.Builder
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := utils.StripANSI(scanner.Text())
+		output.WriteString(line + "\n")
+		if progress != nil {
+			progress(line)
+		}
+	}
+	// Keep the command from blocking on a line too long for the scanner.
+	_, _ = io.Copy(io.Discard, reader)
+
+	err := <-done
+	if ctx.Err() == context.DeadlineExceeded {
+		return output.String(), fmt.Errorf("compose %v: %w", strings.Join(args, " "), ErrTimeout)
+	}
+	if err != nil {
+		return output.String(), fmt.Errorf("compose %v: %w", strings.Join(args, " "), err)
+	}
+	return output.String(), nil
+}
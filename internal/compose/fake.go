@@ -0,0 +1,47 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mrmarble/teledock/internal/config"
+)
+
+// Fake is a Backend with scripted outputs that runs nothing.
+// It is meant to drive the telegram handlers without a compose binary.
+type Fake struct {
+	mu sync.Mutex
+
+	// Outputs holds the output printed by each command, keyed by the space joined args.
+	// Unknown commands print nothing.
+	Outputs map[string]string
+	// Failures holds the commands that fail, keyed the same way.
+	Failures map[string]bool
+	// Calls records every command as "project:args".
+	Calls []string
+}
+
+// NewFake returns a Fake backend where every command succeeds silently.
+func NewFake() *Fake {
+	return &Fake{Outputs: map[string]string{}, Failures: map[string]bool{}}
+}
+
+func (f *Fake) Run(ctx context.Context, project config.Project, args []string, progress func(line string)) (string, error) {
+	f.mu.Lock()
+	command := strings.Join(args, " ")
+	f.Calls = append(f.Calls, fmt.Sprintf("%v:%v", project.Name, command))
+	output, failed := f.Outputs[command], f.Failures[command]
+	f.mu.Unlock()
+
+	if progress != nil && output != "" {
+		for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+			progress(line)
+		}
+	}
+	if failed {
+		return output, fmt.Errorf("compose %v: exit status 1", command)
+	}
+	return output, nil
+}
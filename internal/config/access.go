@@ -0,0 +1,146 @@
+package config
+
+import "github.com/mrmarble/teledock/internal/constants"
+
+// Chat is a group chat whose members can use the bot.
+type Chat struct {
+	ID int64 `json:"id"`
+	// Role is the role every member gets in the chat, operator if not set.
+	Role Role `json:"role"`
+	// Scopes names the scopes of the config file the chat is limited to, none for every container.
+	Scopes []string `json:"scopes"`
+}
+
+// Access is what a user can do in a chat.
+type Access struct {
+	Role Role
+	// scopes are the scope lists of the users and chats granting the access, a
+	// container has to match all of them. A nil list matches every container.
+	scopes [][]Scope
+}
+
+// Unrestricted reports whether the access reaches every container.
+func (a Access) Unrestricted() bool {
+	for _, scopes := range a.scopes {
+		if scopes != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Allows reports whether the container with name and labels can be reached.
+func (a Access) Allows(name string, labels map[string]string) bool {
+	for _, scopes := range a.scopes {
+		if scopes == nil {
+			continue
+		}
+		matched := false
+		for _, scope := range scopes {
+			matched = matched || scope.Matches(name, labels)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Covers reports whether every container matching any of the name globs or label
+// selectors can be reached. Selectors are compared to the ones of the scopes, so
+// only the unrestricted access covers an empty selection, which matches everything.
+func (a Access) Covers(names []string, labels []string) bool {
+	if len(names) == 0 && len(labels) == 0 {
+		return a.Unrestricted()
+	}
+	for _, scopes := range a.scopes {
+		if scopes == nil {
+			continue
+		}
+		for _, name := range names {
+			if !anyScope(scopes, func(scope Scope) bool { return scope.coversName(name) }) {
+				return false
+			}
+		}
+		for _, label := range labels {
+			if !anyScope(scopes, func(scope Scope) bool { return scope.coversLabel(label) }) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func anyScope(scopes []Scope, match func(Scope) bool) bool {
+	for _, scope := range scopes {
+		if match(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProject reports whether the compose project name can be reached, as
+// the containers of the project would be by their compose project label.
+func (a Access) AllowsProject(name string) bool {
+	return a.Allows("", map[string]string{constants.ComposeLabel: name})
+}
+
+// Access returns what userID can do in chatID. In group chats the user gets the
+// higher of its own role and the role of the chat, or the lower one if
+// RequireChat is set so both have to be allowed.
+func (c *Config) Access(chatID, userID int64) Access {
+	user := c.userAccess(userID)
+	if chatID == userID {
+		return user
+	}
+
+	chat := c.chatAccess(chatID)
+	if c.RequireChat {
+		if user.Role == RoleNone || chat.Role == RoleNone {
+			return Access{Role: RoleNone, scopes: [][]Scope{{}}}
+		}
+		role := user.Role
+		if chat.Role < role {
+			role = chat.Role
+		}
+		return Access{Role: role, scopes: append(user.scopes, chat.scopes...)}
+	}
+	if chat.Role > user.Role {
+		return chat
+	}
+	return user
+}
+
+// userAccess returns the highest role given to userID, RoleNone if the user is unknown.
+func (c *Config) userAccess(userID int64) Access {
+	access := Access{Role: RoleNone, scopes: [][]Scope{{}}}
+	for _, user := range c.Users {
+		if user.ID == userID && user.Role > access.Role {
+			access = Access{Role: user.Role, scopes: [][]Scope{c.scopes(user.Scopes)}}
+		}
+	}
+	return access
+}
+
+// chatAccess returns the role given to the members of chatID, RoleNone if the chat is unknown.
+func (c *Config) chatAccess(chatID int64) Access {
+	for _, chat := range c.Chats {
+		if chat.ID == chatID {
+			return Access{Role: chat.Role, scopes: [][]Scope{c.scopes(chat.Scopes)}}
+		}
+	}
+	return Access{Role: RoleNone, scopes: [][]Scope{{}}}
+}
+
+// scopes resolves scope names, nil when there are none.
+func (c *Config) scopes(names []string) []Scope {
+	if len(names) == 0 {
+		return nil
+	}
+	scopes := []Scope{}
+	for _, name := range names {
+		scopes = append(scopes, c.Scopes[name])
+	}
+	return scopes
+}
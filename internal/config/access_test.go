@@ -0,0 +1,138 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/constants"
+)
+
+const (
+	admin    = 1
+	operator = 2
+	viewer   = 3
+	stranger = 4
+	group    = -100
+)
+
+func accessConfig(requireChat bool) *Config {
+	return &Config{
+		Users: []User{
+			{ID: admin, Role: RoleAdmin},
+			{ID: operator, Role: RoleOperator, Scopes: []string{"shop"}},
+			{ID: viewer, Role: RoleViewer},
+		},
+		Chats: []Chat{
+			{ID: group, Role: RoleOperator, Scopes: []string{"blog"}},
+		},
+		Scopes: map[string]Scope{
+			"shop": {Projects: []string{"shop"}},
+			"blog": {Names: []string{"blog-*"}},
+		},
+		RequireChat: requireChat,
+	}
+}
+
+func TestAccessRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		chat, user  int64
+		requireChat bool
+		want        Role
+	}{
+		{"private admin", admin, admin, false, RoleAdmin},
+		{"private stranger", stranger, stranger, false, RoleNone},
+		{"group gives its role", group, stranger, false, RoleOperator},
+		{"group keeps a higher user role", group, admin, false, RoleAdmin},
+		{"group raises a lower user role", group, viewer, false, RoleOperator},
+		{"unknown group", -200, operator, false, RoleOperator},
+		{"required chat takes the lower role", group, admin, true, RoleOperator},
+		{"required chat needs the user", group, stranger, true, RoleNone},
+		{"required chat needs the chat", -200, admin, true, RoleNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accessConfig(tt.requireChat).Access(tt.chat, tt.user).Role; got != tt.want {
+				t.Errorf("Access(%v, %v).Role = %v, want %v", tt.chat, tt.user, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessAllows(t *testing.T) {
+	shop := map[string]string{constants.ComposeLabel: "shop"}
+	tests := []struct {
+		name         string
+		chat, user   int64
+		requireChat  bool
+		container    string
+		labels       map[string]string
+		want         bool
+		unrestricted bool
+	}{
+		{"unscoped user", admin, admin, false, "/anything", nil, true, true},
+		{"scoped user in scope", operator, operator, false, "/shop_web_1", shop, true, false},
+		{"scoped user out of scope", operator, operator, false, "/blog-web", nil, false, false},
+		{"scoped chat in scope", group, stranger, false, "/blog-web", nil, true, false},
+		{"scoped chat out of scope", group, stranger, false, "/shop_web_1", shop, false, false},
+		{"required chat matches both", group, operator, true, "/blog-shop", shop, true, false},
+		{"required chat matches one", group, operator, true, "/shop_web_1", shop, false, false},
+		{"stranger", stranger, stranger, false, "/anything", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := accessConfig(tt.requireChat).Access(tt.chat, tt.user)
+			if got := access.Allows(tt.container, tt.labels); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.container, got, tt.want)
+			}
+			if got := access.Unrestricted(); got != tt.unrestricted {
+				t.Errorf("Unrestricted() = %v, want %v", got, tt.unrestricted)
+			}
+		})
+	}
+}
+
+func TestAccessCovers(t *testing.T) {
+	tests := []struct {
+		name       string
+		chat, user int64
+		names      []string
+		labels     []string
+		want       bool
+	}{
+		{"unscoped user covers everything", admin, admin, nil, nil, true},
+		{"scoped user doesn't cover everything", operator, operator, nil, nil, false},
+		{"compose project", operator, operator, nil, []string{constants.ComposeLabel + "=shop"}, true},
+		{"other compose project", operator, operator, nil, []string{constants.ComposeLabel + "=blog"}, false},
+		{"same glob", group, stranger, []string{"blog-*"}, nil, true},
+		{"name within the glob", group, stranger, []string{"blog-web"}, nil, true},
+		{"wider glob", group, stranger, []string{"blog*"}, nil, false},
+		{"one selector out of reach", group, stranger, []string{"blog-*", "shop-*"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := accessConfig(false).Access(tt.chat, tt.user)
+			if got := access.Covers(tt.names, tt.labels); got != tt.want {
+				t.Errorf("Covers(%q, %q) = %v, want %v", tt.names, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessAllowsProject(t *testing.T) {
+	cfg := accessConfig(false)
+	tests := []struct {
+		user    int64
+		project string
+		want    bool
+	}{
+		{admin, "blog", true},
+		{operator, "shop", true},
+		{operator, "blog", false},
+		{stranger, "shop", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.Access(tt.user, tt.user).AllowsProject(tt.project); got != tt.want {
+			t.Errorf("user %v AllowsProject(%q) = %v, want %v", tt.user, tt.project, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertMetrics are the resource usages alert rules can watch, both in percent.
+// CPU is relative to one CPU and memory to the limit of the container.
+var alertMetrics = map[string]bool{"cpu": true, "mem": true}
+
+// alertName restricts rule names to what fits in callback data.
+var alertName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,24}$`)
+
+// AlertRule alerts chats when the resource usage of a container stays over a threshold.
+type AlertRule struct {
+	// Name identifies the rule in /alerts.
+	Name string `json:"name"`
+	// Rule is the condition, like "mem > 90% for 5m" or "cpu > 200% for 10m".
+	Rule string `json:"rule"`
+	// Names are container name globs and Labels "key=value" selectors, like in
+	// scopes. The rule watches every container if both are empty.
+	Names  []string `json:"names"`
+	Labels []string `json:"labels"`
+	// Chats receive the alerts, the notification chats if empty.
+	Chats []int64 `json:"chats"`
+
+	// Metric, Threshold and For are parsed from Rule.
+	Metric    string        `json:"-"`
+	Threshold float64       `json:"-"`
+	For       time.Duration `json:"-"`
+}
+
+// Parse validates the rule and fills Metric, Threshold and For out of Rule.
+func (r *AlertRule) Parse() error {
+	if !alertName.MatchString(r.Name) {
+		return fmt.Errorf("invalid name %q, use up to 24 letters, digits, dots, dashes or underscores", r.Name)
+	}
+	if err := (Scope{Names: r.Names, Labels: r.Labels}).Validate(); err != nil {
+		return err
+	}
+
+	fields := strings.Fields(r.Rule)
+	if len(fields) < 3 || fields[1] != ">" || !strings.HasSuffix(fields[2], "%") {
+		return fmt.Errorf("invalid rule %q, expected \"<cpu|mem> > <percent>%% [for <duration>]\"", r.Rule)
+	}
+	if !alertMetrics[fields[0]] {
+		return fmt.Errorf("invalid rule %q, unknown metric %q", r.Rule, fields[0])
+	}
+	threshold, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "%"), 64)
+	if err != nil || threshold <= 0 {
+		return fmt.Errorf("invalid rule %q, bad threshold %q", r.Rule, fields[2])
+	}
+	rest := fields[3:]
+	if fields[0] == "mem" && len(rest) >= 2 && rest[0] == "of" && rest[1] == "limit" {
+		rest = rest[2:]
+	}
+
+	r.Metric, r.Threshold, r.For = fields[0], threshold, 0
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 2 && rest[0] == "for":
+		if r.For, err = time.ParseDuration(rest[1]); err != nil || r.For < 0 {
+			return fmt.Errorf("invalid rule %q, bad duration %q", r.Rule, rest[1])
+		}
+	default:
+		return fmt.Errorf("invalid rule %q, unexpected %q", r.Rule, strings.Join(rest, " "))
+	}
+	return nil
+}
+
+// Matches reports whether the rule watches the container with name and labels.
+func (r AlertRule) Matches(name string, labels map[string]string) bool {
+	if len(r.Names) == 0 && len(r.Labels) == 0 {
+		return true
+	}
+	return Scope{Names: r.Names, Labels: r.Labels}.Matches(name, labels)
+}
+
+// AlertChats returns the chats the alerts of r are sent to.
+func (c *Config) AlertChats(r AlertRule) []int64 {
+	if len(r.Chats) > 0 {
+		return r.Chats
+	}
+	chats := []int64{}
+	seen := map[int64]bool{}
+	for _, notification := range c.Notifications {
+		for _, chatID := range notification.Chats {
+			if !seen[chatID] {
+				seen[chatID] = true
+				chats = append(chats, chatID)
+			}
+		}
+	}
+	return chats
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertRuleParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      AlertRule
+		metric    string
+		threshold float64
+		duration  time.Duration
+		wantErr   bool
+	}{
+		{name: "cpu", rule: AlertRule{Name: "busy", Rule: "cpu > 80%"}, metric: "cpu", threshold: 80},
+		{name: "over a cpu", rule: AlertRule{Name: "busy", Rule: "cpu > 250% for 10m"}, metric: "cpu", threshold: 250, duration: 10 * time.Minute},
+		{name: "mem of limit", rule: AlertRule{Name: "oom.soon", Rule: "mem > 90.5% of limit for 5m"}, metric: "mem", threshold: 90.5, duration: 5 * time.Minute},
+		{name: "mem", rule: AlertRule{Name: "oom_soon", Rule: "mem > 90%"}, metric: "mem", threshold: 90},
+		{name: "bad name", rule: AlertRule{Name: "no spaces", Rule: "cpu > 80%"}, wantErr: true},
+		{name: "long name", rule: AlertRule{Name: "a-name-way-over-the-limit", Rule: "cpu > 80%"}, wantErr: true},
+		{name: "bad glob", rule: AlertRule{Name: "busy", Rule: "cpu > 80%", Names: []string{"web-["}}, wantErr: true},
+		{name: "unknown metric", rule: AlertRule{Name: "busy", Rule: "disk > 80%"}, wantErr: true},
+		{name: "no percent", rule: AlertRule{Name: "busy", Rule: "cpu > 80"}, wantErr: true},
+		{name: "wrong operator", rule: AlertRule{Name: "busy", Rule: "cpu < 80%"}, wantErr: true},
+		{name: "zero threshold", rule: AlertRule{Name: "busy", Rule: "cpu > 0%"}, wantErr: true},
+		{name: "bad duration", rule: AlertRule{Name: "busy", Rule: "cpu > 80% for ever"}, wantErr: true},
+		{name: "cpu of limit", rule: AlertRule{Name: "busy", Rule: "cpu > 80% of limit"}, wantErr: true},
+		{name: "trailing words", rule: AlertRule{Name: "busy", Rule: "cpu > 80% for 5m please"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			err := rule.Parse()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%q) succeeded, want an error", rule.Rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", rule.Rule, err)
+			}
+			if rule.Metric != tt.metric || rule.Threshold != tt.threshold || rule.For != tt.duration {
+				t.Errorf("Parse(%q) = %v > %v for %v, want %v > %v for %v", rule.Rule, rule.Metric, rule.Threshold, rule.For, tt.metric, tt.threshold, tt.duration)
+			}
+		})
+	}
+}
+
+func TestAlertRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule   AlertRule
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{AlertRule{}, "/anything", nil, true},
+		{AlertRule{Names: []string{"web-*"}}, "/web-1", nil, true},
+		{AlertRule{Names: []string{"web-*"}}, "/db", nil, false},
+		{AlertRule{Labels: []string{"alerts=on"}}, "/db", map[string]string{"alerts": "on"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Matches(tt.name, tt.labels); got != tt.want {
+			t.Errorf("%+v Matches(%q) = %v, want %v", tt.rule, tt.name, got, tt.want)
+		}
+	}
+}
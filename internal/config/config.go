@@ -0,0 +1,270 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/utils"
+)
+
+// defaultAuditFile is where actions are recorded when TELEDOCK_AUDIT_FILE is not set.
+const defaultAuditFile = "audit.jsonl"
+
+// Config holds the bot settings read from the environment.
+type Config struct {
+	Token string
+	// Users are the users allowed to use the bot and their roles.
+	Users []User
+	// Commands overrides the role required by a command, by command name.
+	Commands map[string]Role
+	// Chats are the group chats whose members can use the bot.
+	Chats []Chat
+	// RequireChat only lets users use the bot in group chats that are allowed too.
+	RequireChat bool
+	// Scopes are the named container scopes users and chats can be limited to.
+	Scopes map[string]Scope
+	// ExecTimeout bounds how long a command run with /exec may take.
+	ExecTimeout time.Duration
+	// ShellIdleTimeout closes /shell sessions that received no input for this long.
+	ShellIdleTimeout time.Duration
+	// ConfirmActions are the actions that ask "Are you sure?" before running.
+	ConfirmActions []string
+	// ConfirmTimeout is how long a confirmation stays valid.
+	ConfirmTimeout time.Duration
+	// DocumentThreshold is the output size, in characters, above which output is sent as a file.
+	DocumentThreshold int
+	// DocumentGzip compresses the files sent instead of long messages.
+	DocumentGzip bool
+	// CallbackSecret signs the data of inline buttons, a random one is used if empty.
+	CallbackSecret string
+	// CallbackTimeout is how long inline buttons keep working.
+	CallbackTimeout time.Duration
+	// AuditFile is the JSONL file every action is recorded to, empty to disable it.
+	// It defaults to defaultAuditFile.
+	AuditFile string
+	// AuditChat is a chat every action is forwarded to, 0 to disable it.
+	AuditChat int64
+	// Notifications are the container events sent to chats.
+	Notifications []Notification
+	// CrashLoopRestarts is how many times a container has to die within
+	// CrashLoopWindow to be reported as crash looping.
+	CrashLoopRestarts int
+	CrashLoopWindow   time.Duration
+	// StatsInterval is how often the resource usage of the containers is sampled, 0 to disable it.
+	StatsInterval time.Duration
+	// StatsRetention is how long resource usage samples are kept.
+	StatsRetention time.Duration
+	// Alerts are the resource usage alert rules.
+	Alerts []AlertRule
+	// Projects are the compose projects on the host, by name.
+	Projects map[string]Project
+	// ComposeCommand is the compose binary and its first arguments, like "docker compose".
+	ComposeCommand []string
+	// ComposeTimeout bounds how long a compose command may take.
+	ComposeTimeout time.Duration
+}
+
+// Load reads the configuration from the TELEDOCK_* environment variables.
+func Load() (*Config, error) {
+	if os.Getenv("TELEDOCK_TOKEN") == "" {
+		return nil, fmt.Errorf("missing environment variable TELEDOCK_TOKEN")
+	}
+
+	cfg := &Config{
+		Token:             os.Getenv("TELEDOCK_TOKEN"),
+		Users:             []User{},
+		Chats:             []Chat{},
+		Notifications:     []Notification{},
+		Alerts:            []AlertRule{},
+		Projects:          map[string]Project{},
+		ComposeCommand:    []string{"docker", "compose"},
+		ComposeTimeout:    10 * time.Minute,
+		Commands:          map[string]Role{},
+		Scopes:            map[string]Scope{},
+		ExecTimeout:       30 * time.Second,
+		ShellIdleTimeout:  5 * time.Minute,
+		ConfirmActions:    []string{"stop", "kill", "remove", "prune", "down"},
+		ConfirmTimeout:    30 * time.Second,
+		DocumentThreshold: 4 * 3000,
+		CallbackSecret:    os.Getenv("TELEDOCK_CALLBACK_SECRET"),
+		CallbackTimeout:   time.Hour,
+		AuditFile:         defaultAuditFile,
+		CrashLoopRestarts: 3,
+		CrashLoopWindow:   5 * time.Minute,
+		StatsInterval:     30 * time.Second,
+		StatsRetention:    24 * time.Hour,
+	}
+
+	if superAdmins := os.Getenv("TELEDOCK_SUPERADMINS"); superAdmins != "" {
+		for _, uidStr := range strings.Split(superAdmins, ",") {
+			uid, err := utils.ParseInt64(strings.TrimSpace(uidStr))
+			if err != nil {
+				return nil, fmt.Errorf("failed parsing superadmins list: %w", err)
+			}
+			cfg.Users = append(cfg.Users, User{ID: uid, Role: RoleAdmin})
+		}
+	}
+	if chats := os.Getenv("TELEDOCK_CHATS"); chats != "" {
+		for _, chatStr := range strings.Split(chats, ",") {
+			chatID, err := utils.ParseInt64(strings.TrimSpace(chatStr))
+			if err != nil {
+				return nil, fmt.Errorf("failed parsing chats list: %w", err)
+			}
+			cfg.Chats = append(cfg.Chats, Chat{ID: chatID, Role: RoleOperator})
+		}
+	}
+	if notifyChats := os.Getenv("TELEDOCK_NOTIFY_CHATS"); notifyChats != "" {
+		notification := Notification{}
+		for _, chatStr := range strings.Split(notifyChats, ",") {
+			chatID, err := utils.ParseInt64(strings.TrimSpace(chatStr))
+			if err != nil {
+				return nil, fmt.Errorf("failed parsing notify chats list: %w", err)
+			}
+			notification.Chats = append(notification.Chats, chatID)
+		}
+		parseList("TELEDOCK_NOTIFY_EVENTS", &notification.Events)
+		if err := notification.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid TELEDOCK_NOTIFY_EVENTS: %w", err)
+		}
+		cfg.Notifications = append(cfg.Notifications, notification)
+	}
+	if path := os.Getenv("TELEDOCK_CONFIG"); path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Users) == 0 && len(cfg.Chats) == 0 {
+		return nil, fmt.Errorf("no users configured, set TELEDOCK_SUPERADMINS or add users to TELEDOCK_CONFIG")
+	}
+
+	if err := parseDuration("TELEDOCK_EXEC_TIMEOUT", &cfg.ExecTimeout); err != nil {
+		return nil, err
+	}
+	if err := parseDuration("TELEDOCK_SHELL_TIMEOUT", &cfg.ShellIdleTimeout); err != nil {
+		return nil, err
+	}
+	if err := parseDuration("TELEDOCK_CONFIRM_TIMEOUT", &cfg.ConfirmTimeout); err != nil {
+		return nil, err
+	}
+	if err := parseDuration("TELEDOCK_CALLBACK_TIMEOUT", &cfg.CallbackTimeout); err != nil {
+		return nil, err
+	}
+	if err := parseDuration("TELEDOCK_CRASHLOOP_WINDOW", &cfg.CrashLoopWindow); err != nil {
+		return nil, err
+	}
+	if err := parseDuration("TELEDOCK_STATS_INTERVAL", &cfg.StatsInterval); err != nil {
+		return nil, err
+	}
+	if err := parseDuration("TELEDOCK_STATS_RETENTION", &cfg.StatsRetention); err != nil {
+		return nil, err
+	}
+	if cfg.StatsInterval > 0 && cfg.StatsRetention < cfg.StatsInterval {
+		return nil, fmt.Errorf("invalid TELEDOCK_STATS_RETENTION: it has to be at least TELEDOCK_STATS_INTERVAL")
+	}
+	if len(cfg.Alerts) > 0 && cfg.StatsInterval <= 0 {
+		return nil, fmt.Errorf("alerts need resource usage sampling, TELEDOCK_STATS_INTERVAL can't be 0")
+	}
+	for _, rule := range cfg.Alerts {
+		if len(cfg.AlertChats(rule)) == 0 {
+			return nil, fmt.Errorf("alert %v has no chats and there are no notification chats", rule.Name)
+		}
+	}
+	if err := parseDuration("TELEDOCK_COMPOSE_TIMEOUT", &cfg.ComposeTimeout); err != nil {
+		return nil, err
+	}
+	if command := strings.Fields(os.Getenv("TELEDOCK_COMPOSE_COMMAND")); len(command) > 0 {
+		cfg.ComposeCommand = command
+	}
+	parseList("TELEDOCK_CONFIRM", &cfg.ConfirmActions)
+	if err := parseInt("TELEDOCK_DOCUMENT_THRESHOLD", &cfg.DocumentThreshold); err != nil {
+		return nil, err
+	}
+	if err := parseInt("TELEDOCK_CRASHLOOP_RESTARTS", &cfg.CrashLoopRestarts); err != nil {
+		return nil, err
+	}
+	if cfg.CrashLoopRestarts < 2 {
+		return nil, fmt.Errorf("invalid TELEDOCK_CRASHLOOP_RESTARTS: it has to be at least 2")
+	}
+	if err := parseBool("TELEDOCK_DOCUMENT_GZIP", &cfg.DocumentGzip); err != nil {
+		return nil, err
+	}
+	if err := parseBool("TELEDOCK_REQUIRE_CHAT", &cfg.RequireChat); err != nil {
+		return nil, err
+	}
+	// Auditing is on unless TELEDOCK_AUDIT_FILE is set to nothing.
+	if path, ok := os.LookupEnv("TELEDOCK_AUDIT_FILE"); ok {
+		cfg.AuditFile = path
+	}
+	if auditChat := os.Getenv("TELEDOCK_AUDIT_CHAT"); auditChat != "" {
+		chatID, err := utils.ParseInt64(auditChat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TELEDOCK_AUDIT_CHAT: %w", err)
+		}
+		cfg.AuditChat = chatID
+	}
+
+	return cfg, nil
+}
+
+// parseDuration overrides value with the duration in envVar, if set.
+func parseDuration(envVar string, value *time.Duration) error {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return nil
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %v: %w", envVar, err)
+	}
+	*value = duration
+	return nil
+}
+
+// parseList overrides value with the comma separated list in envVar, if set.
+// The special value "none" sets an empty list.
+func parseList(envVar string, value *[]string) {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return
+	}
+	*value = []string{}
+	if raw == "none" {
+		return
+	}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*value = append(*value, item)
+		}
+	}
+}
+
+// parseInt overrides value with the integer in envVar, if set.
+func parseInt(envVar string, value *int) error {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return nil
+	}
+	number, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %v: %w", envVar, err)
+	}
+	*value = number
+	return nil
+}
+
+// parseBool overrides value with the boolean in envVar, if set.
+func parseBool(envVar string, value *bool) error {
+	raw := os.Getenv(envVar)
+	if raw == "" {
+		return nil
+	}
+	boolean, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %v: %w", envVar, err)
+	}
+	*value = boolean
+	return nil
+}
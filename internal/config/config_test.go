@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoadAuditFile(t *testing.T) {
+	tests := []struct {
+		name string
+		env  *string
+		want string
+	}{
+		{"unset", nil, defaultAuditFile},
+		{"set", stringPtr("/data/audit.jsonl"), "/data/audit.jsonl"},
+		{"disabled", stringPtr(""), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TELEDOCK_TOKEN", "token")
+			t.Setenv("TELEDOCK_SUPERADMINS", "1")
+			t.Setenv("TELEDOCK_AUDIT_FILE", "")
+			if tt.env == nil {
+				os.Unsetenv("TELEDOCK_AUDIT_FILE")
+			} else {
+				t.Setenv("TELEDOCK_AUDIT_FILE", *tt.env)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.AuditFile != tt.want {
+				t.Errorf("AuditFile = %q, want %q", cfg.AuditFile, tt.want)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// file is the layout of the optional JSON config file set in TELEDOCK_CONFIG.
+type file struct {
+	Users         []User             `json:"users"`
+	Chats         []Chat             `json:"chats"`
+	Commands      map[string]Role    `json:"commands"`
+	Scopes        map[string]Scope   `json:"scopes"`
+	Notifications []Notification     `json:"notifications"`
+	Alerts        []AlertRule        `json:"alerts"`
+	Projects      map[string]Project `json:"projects"`
+}
+
+// loadFile adds the settings of the JSON config file at path to cfg.
+func loadFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading config file: %w", err)
+	}
+
+	var contents file
+	if err := json.Unmarshal(raw, &contents); err != nil {
+		return fmt.Errorf("failed parsing config file %v: %w", path, err)
+	}
+
+	for name, scope := range contents.Scopes {
+		if err := scope.Validate(); err != nil {
+			return fmt.Errorf("invalid scope %v in config file %v: %w", name, path, err)
+		}
+		cfg.Scopes[name] = scope
+	}
+	for _, user := range contents.Users {
+		if user.ID == 0 || user.Role == RoleNone {
+			return fmt.Errorf("invalid user in config file %v: an id and a role are required", path)
+		}
+		if err := checkScopes(cfg, user.Scopes); err != nil {
+			return fmt.Errorf("invalid user %v in config file %v: %w", user.ID, path, err)
+		}
+		cfg.Users = append(cfg.Users, user)
+	}
+	for _, chat := range contents.Chats {
+		if chat.ID == 0 {
+			return fmt.Errorf("invalid chat in config file %v: an id is required", path)
+		}
+		if chat.Role == RoleNone {
+			chat.Role = RoleOperator
+		}
+		if err := checkScopes(cfg, chat.Scopes); err != nil {
+			return fmt.Errorf("invalid chat %v in config file %v: %w", chat.ID, path, err)
+		}
+		cfg.Chats = append(cfg.Chats, chat)
+	}
+	for index, notification := range contents.Notifications {
+		if err := notification.Validate(); err != nil {
+			return fmt.Errorf("invalid notification %v in config file %v: %w", index+1, path, err)
+		}
+		if err := checkScopes(cfg, notification.Scopes); err != nil {
+			return fmt.Errorf("invalid notification %v in config file %v: %w", index+1, path, err)
+		}
+		cfg.Notifications = append(cfg.Notifications, notification)
+	}
+	for index, rule := range contents.Alerts {
+		if err := rule.Parse(); err != nil {
+			return fmt.Errorf("invalid alert %v in config file %v: %w", index+1, path, err)
+		}
+		for _, other := range cfg.Alerts {
+			if other.Name == rule.Name {
+				return fmt.Errorf("invalid alert %v in config file %v: duplicated name %v", index+1, path, rule.Name)
+			}
+		}
+		cfg.Alerts = append(cfg.Alerts, rule)
+	}
+	for name, project := range contents.Projects {
+		project.Name = name
+		if err := project.Validate(); err != nil {
+			return fmt.Errorf("invalid project %v in config file %v: %w", name, path, err)
+		}
+		cfg.Projects[name] = project
+	}
+	for command, role := range contents.Commands {
+		// Unknown users have no role, a command can't be opened up to them.
+		if role < RoleViewer {
+			return fmt.Errorf("invalid command %v in config file %v: the role can't be lower than viewer", command, path)
+		}
+		cfg.Commands[command] = role
+	}
+	return nil
+}
+
+// checkScopes fails if any of names is not a scope of cfg.
+func checkScopes(cfg *Config, names []string) error {
+	for _, name := range names {
+		if _, ok := cfg.Scopes[name]; !ok {
+			return fmt.Errorf("unknown scope %v", name)
+		}
+	}
+	return nil
+}
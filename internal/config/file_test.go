@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFileCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands string
+		want     Role
+		wantErr  string
+	}{
+		{"lowers a role", `{"exec": "operator"}`, RoleOperator, ""},
+		{"raises a role", `{"exec": "ADMIN"}`, RoleAdmin, ""},
+		{"none", `{"exec": "none"}`, RoleNone, "can't be lower than viewer"},
+		{"unknown role", `{"exec": "root"}`, RoleNone, "unknown role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(`{"commands": `+tt.commands+`}`), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg := &Config{Commands: map[string]Role{}, Scopes: map[string]Scope{}, Projects: map[string]Project{}}
+			err := loadFile(path, cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadFile() error = %v", err)
+			}
+			if role := cfg.Commands["exec"]; role != tt.want {
+				t.Errorf("exec role = %v, want %v", role, tt.want)
+			}
+		})
+	}
+}
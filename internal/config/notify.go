@@ -0,0 +1,62 @@
+package config
+
+import "fmt"
+
+// DefaultNotifyEvents are the container events notified when a notification doesn't list any.
+var DefaultNotifyEvents = []string{"die", "oom", "unhealthy", "restart", "start", "stop", "crashloop"}
+
+// notifyEvents are the container events that can be notified, crashloop is sent
+// once for containers dying over and over instead of each die.
+var notifyEvents = map[string]bool{
+	"start": true, "stop": true, "restart": true, "die": true, "kill": true,
+	"oom": true, "healthy": true, "unhealthy": true, "pause": true, "unpause": true,
+	"crashloop": true,
+}
+
+// Notification sends container events to chats.
+type Notification struct {
+	Chats []int64 `json:"chats"`
+	// Events are the event actions to send, DefaultNotifyEvents if empty.
+	Events []string `json:"events"`
+	// Scopes names the scopes of the config file the containers have to be in, none for every container.
+	Scopes []string `json:"scopes"`
+}
+
+// Validate checks the chats and events of the notification.
+func (n Notification) Validate() error {
+	if len(n.Chats) == 0 {
+		return fmt.Errorf("at least one chat is required")
+	}
+	for _, event := range n.Events {
+		if !notifyEvents[event] {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// Notifies reports whether n sends the event action of the container with name and labels.
+func (c *Config) Notifies(n Notification, action string, name string, labels map[string]string) bool {
+	events := n.Events
+	if len(events) == 0 {
+		events = DefaultNotifyEvents
+	}
+	wanted := false
+	for _, event := range events {
+		wanted = wanted || event == action
+	}
+	if !wanted {
+		return false
+	}
+
+	scopes := c.scopes(n.Scopes)
+	if scopes == nil {
+		return true
+	}
+	for _, scope := range scopes {
+		if scope.Matches(name, labels) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// projectName restricts project names to what compose accepts and fits in callback data.
+var projectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,19}$`)
+
+// Project is a compose project directory on the host the bot can bring up and down.
+type Project struct {
+	// Name is the compose project name, the key of the project in the config file.
+	Name string `json:"-"`
+	// Dir is the absolute path of the project directory.
+	Dir string `json:"dir"`
+	// Files are the compose files, relative to Dir. The compose defaults if empty.
+	Files []string `json:"files"`
+}
+
+// Validate checks the name and directory of the project.
+func (p Project) Validate() error {
+	if !projectName.MatchString(p.Name) {
+		return fmt.Errorf("invalid name %q, use up to 20 lowercase letters, digits, dashes or underscores", p.Name)
+	}
+	if !filepath.IsAbs(p.Dir) {
+		return fmt.Errorf("invalid dir %q, it has to be an absolute path", p.Dir)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is the access level of a user, higher roles can do everything lower ones can.
+type Role int
+
+const (
+	// RoleNone is the role of unknown users.
+	RoleNone Role = iota
+	// RoleViewer can list and read containers, images, stacks and logs.
+	RoleViewer
+	// RoleOperator can also start, stop, restart, pause and kill containers.
+	RoleOperator
+	// RoleAdmin can do everything, including creating containers and running commands in them.
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleNone:     "none",
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+// ParseRole returns the role named name.
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if strings.EqualFold(strings.TrimSpace(name), roleName) {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q, expected viewer, operator or admin", name)
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// UnmarshalText reads a role by name from the config file.
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// MarshalText writes a role by name.
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// User is a telegram user allowed to use the bot.
+type User struct {
+	ID   int64 `json:"id"`
+	Role Role  `json:"role"`
+	// Scopes names the scopes of the config file the user is limited to, none for every container.
+	Scopes []string `json:"scopes"`
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mrmarble/teledock/internal/constants"
+)
+
+// Scope limits the containers a user can see and act on. A container is in the
+// scope when it matches any of its name globs, labels or compose projects.
+type Scope struct {
+	// Names are container name globs, like "web-*".
+	Names []string `json:"names"`
+	// Labels are "key=value" selectors, or just "key" to match any value.
+	Labels []string `json:"labels"`
+	// Projects are compose project names.
+	Projects []string `json:"projects"`
+}
+
+// Validate checks the name globs and label selectors of the scope.
+func (s Scope) Validate() error {
+	for _, name := range s.Names {
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Errorf("invalid name glob %q: %w", name, err)
+		}
+	}
+	for _, label := range s.Labels {
+		if strings.HasPrefix(label, "=") || label == "" {
+			return fmt.Errorf("invalid label selector %q, expected key=value or key", label)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the container with name and labels is in the scope.
+func (s Scope) Matches(name string, labels map[string]string) bool {
+	name = strings.TrimPrefix(name, "/")
+	for _, glob := range s.Names {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	for _, selector := range s.Labels {
+		key, value, hasValue := cut(selector, "=")
+		if actual, ok := labels[key]; ok && (!hasValue || actual == value) {
+			return true
+		}
+	}
+	for _, project := range s.Projects {
+		if labels[constants.ComposeLabel] == project {
+			return true
+		}
+	}
+	return false
+}
+
+// coversName reports whether every container name matching glob is in the scope,
+// as the same glob or a name matching one of the name globs of the scope.
+func (s Scope) coversName(glob string) bool {
+	for _, name := range s.Names {
+		if name == glob {
+			return true
+		}
+		if ok, _ := path.Match(name, glob); ok && !strings.ContainsAny(glob, `*?[\`) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversLabel reports whether every container matching the label selector is in
+// the scope, as the same selector, a selector on any value of its key or a
+// compose project of the scope.
+func (s Scope) coversLabel(selector string) bool {
+	key, value, hasValue := cut(selector, "=")
+	for _, label := range s.Labels {
+		if label == selector || label == key {
+			return true
+		}
+	}
+	for _, project := range s.Projects {
+		if hasValue && key == constants.ComposeLabel && value == project {
+			return true
+		}
+	}
+	return false
+}
+
+// cut is strings.Cut, missing in go 1.17.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
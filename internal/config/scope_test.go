@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/constants"
+)
+
+func TestScopeMatches(t *testing.T) {
+	scope := Scope{
+		Names:    []string{"web-*"},
+		Labels:   []string{"team=shop", "monitored"},
+		Projects: []string{"blog"},
+	}
+	tests := []struct {
+		name      string
+		container string
+		labels    map[string]string
+		want      bool
+	}{
+		{"name glob", "/web-1", nil, true},
+		{"name glob without slash", "web-api", nil, true},
+		{"other name", "/db", nil, false},
+		{"label value", "/db", map[string]string{"team": "shop"}, true},
+		{"other label value", "/db", map[string]string{"team": "blog"}, false},
+		{"label key", "/db", map[string]string{"monitored": ""}, true},
+		{"compose project", "/blog_db_1", map[string]string{constants.ComposeLabel: "blog"}, true},
+		{"other compose project", "/shop_db_1", map[string]string{constants.ComposeLabel: "shop"}, false},
+		{"nothing", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scope.Matches(tt.container, tt.labels); got != tt.want {
+				t.Errorf("Matches(%q, %v) = %v, want %v", tt.container, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeValidate(t *testing.T) {
+	tests := []struct {
+		scope   Scope
+		wantErr bool
+	}{
+		{Scope{Names: []string{"web-*"}, Labels: []string{"a=b", "c"}}, false},
+		{Scope{Names: []string{"web-["}}, true},
+		{Scope{Labels: []string{"=b"}}, true},
+		{Scope{Labels: []string{""}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.scope.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, want error %v", tt.scope, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScopeCovers(t *testing.T) {
+	scope := Scope{Names: []string{"web-*"}, Labels: []string{"team=shop", "monitored"}, Projects: []string{"blog"}}
+	names := []struct {
+		glob string
+		want bool
+	}{
+		{"web-*", true},
+		{"web-api", true},
+		{"web-api-*", false},
+		{"db", false},
+	}
+	for _, tt := range names {
+		if got := scope.coversName(tt.glob); got != tt.want {
+			t.Errorf("coversName(%q) = %v, want %v", tt.glob, got, tt.want)
+		}
+	}
+	labels := []struct {
+		selector string
+		want     bool
+	}{
+		{"team=shop", true},
+		{"team=blog", false},
+		{"team", false},
+		{"monitored", true},
+		{"monitored=yes", true},
+		{constants.ComposeLabel + "=blog", true},
+		{constants.ComposeLabel, false},
+	}
+	for _, tt := range labels {
+		if got := scope.coversLabel(tt.selector); got != tt.want {
+			t.Errorf("coversLabel(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
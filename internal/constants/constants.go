@@ -1,4 +1,6 @@
 package constants
 
 const ComposeLabel = "com.docker.compose.project"
+const ComposeServiceLabel = "com.docker.compose.service"
+const ComposeDependsOnLabel = "com.docker.compose.depends_on"
 const FormatedStrPadded = "<code> %-8v</code><code>%v</code>"
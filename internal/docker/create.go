@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+)
+
+// RestartPolicies lists the restart policies a container can be created with.
+var RestartPolicies = []string{"no", "always", "unless-stopped", "on-failure"}
+
+// ContainerSpec describes a container to be created.
+type ContainerSpec struct {
+	Image string
+	Name  string
+	// Ports are published ports in "[ip:]hostPort:containerPort[/proto]" form.
+	Ports []string
+	// Env holds "KEY=value" pairs.
+	Env []string
+	// Volumes are binds in "source:destination[:mode]" form.
+	Volumes       []string
+	RestartPolicy string
+}
+
+// Validate checks the spec without talking to the daemon.
+func (s ContainerSpec) Validate() error {
+	if s.Image == "" {
+		return fmt.Errorf("an image is required")
+	}
+	if _, _, err := nat.ParsePortSpecs(s.Ports); err != nil {
+		return err
+	}
+	for _, env := range s.Env {
+		if !strings.Contains(env, "=") || strings.HasPrefix(env, "=") {
+			return fmt.Errorf("invalid environment variable %q, expected KEY=value", env)
+		}
+	}
+	for _, volume := range s.Volumes {
+		if parts := strings.Split(volume, ":"); len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid volume %q, expected source:destination[:mode]", volume)
+		}
+	}
+	if s.RestartPolicy != "" && !contains(RestartPolicies, s.RestartPolicy) {
+		return fmt.Errorf("invalid restart policy %q", s.RestartPolicy)
+	}
+	return nil
+}
+
+// Create creates a container from spec and returns its ID. The container is not started.
+func (d *Docker) Create(spec ContainerSpec) (string, error) {
+	if err := spec.Validate(); err != nil {
+		return "", &Error{Op: "create", Err: err}
+	}
+	if err := d.reconnect(); err != nil {
+		return "", err
+	}
+
+	exposedPorts, portBindings, _ := nat.ParsePortSpecs(spec.Ports)
+	created, err := d.cli.ContainerCreate(d.ctx,
+		&container.Config{
+			Image:        spec.Image,
+			Env:          spec.Env,
+			ExposedPorts: exposedPorts,
+		},
+		&container.HostConfig{
+			Binds:         spec.Volumes,
+			PortBindings:  portBindings,
+			RestartPolicy: container.RestartPolicy{Name: spec.RestartPolicy},
+		},
+		nil, nil, spec.Name)
+	if err != nil {
+		return "", d.wrap("create", spec.Name, err)
+	}
+	for _, warning := range created.Warnings {
+		log.Warn().Str("containerID", created.ID).Msg(warning)
+	}
+	return created.ID, nil
+}
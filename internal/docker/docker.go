@@ -2,15 +2,16 @@ package docker
 
 import (
 	"context"
-	"io"
+	"fmt"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/mrmarble/teledock/internal/constants"
-	"github.com/mrmarble/teledock/internal/utils"
 
 	"github.com/rs/zerolog"
 	zero "github.com/rs/zerolog/log"
@@ -22,6 +23,12 @@ var log zerolog.Logger
 type Docker struct {
 	cli *client.Client
 	ctx context.Context
+
+	// mu guards healthy, which is cleared when the daemon becomes unreachable.
+	mu      sync.Mutex
+	healthy bool
+	// selfID is the ID of the container teledock runs in, if any.
+	selfID string
 }
 
 func NewDocker() (*Docker, error) {
@@ -33,104 +40,168 @@ func NewDocker() (*Docker, error) {
 	}
 	ctx := context.Background()
 	log.Info().Msg("connected to the docker daemon")
-	return &Docker{cli, ctx}, nil
+
+	d := &Docker{cli: cli, ctx: ctx, healthy: true}
+	if d.selfID = d.detectSelf(); d.selfID != "" {
+		log.Info().Str("containerID", d.selfID[:12]).Msg("running inside a container")
+	}
+	return d, nil
 }
 
-func (d *Docker) Ping() {
+func (d *Docker) Ping() error {
 	ping, err := d.cli.Ping(d.ctx)
 
 	if err != nil {
-		log.Fatal().Err(err).Msg("error pinging docker")
+		return d.wrap("ping", "", err)
 	}
 	log.Info().Str("Api-version", ping.APIVersion).Msg("docker daemon health check")
+	return nil
+}
+
+// reconnect checks the daemon again after an outage and renegotiates the API version,
+// which the client pins to the oldest one when the first request fails.
+func (d *Docker) reconnect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.healthy {
+		return nil
+	}
+	if _, err := d.cli.Ping(d.ctx); err != nil {
+		return newError("ping", "", err)
+	}
+	d.cli.NegotiateAPIVersion(d.ctx)
+	d.healthy = true
+	log.Info().Str("Api-version", d.cli.ClientVersion()).Msg("reconnected to the docker daemon")
+	return nil
+}
+
+// wrap classifies err and flags the connection as broken when the daemon is unreachable.
+func (d *Docker) wrap(op, containerID string, err error) error {
+	wrapped := newError(op, containerID, err)
+	if wrapped == nil {
+		return nil
+	}
+	if dErr, ok := wrapped.(*Error); ok && dErr.Kind == ErrUnavailable {
+		d.mu.Lock()
+		if d.healthy {
+			log.Warn().Err(err).Msg("lost connection to the docker daemon")
+		}
+		d.healthy = false
+		d.mu.Unlock()
+	}
+	log.Error().Str("op", op).Str("containerID", containerID).Err(err).Msg("docker operation failed")
+	return wrapped
 }
 
-func (d *Docker) List(options types.ContainerListOptions) []types.Container {
+func (d *Docker) List(options types.ContainerListOptions) ([]types.Container, error) {
+	if err := d.reconnect(); err != nil {
+		return nil, err
+	}
 	containers, err := d.cli.ContainerList(d.ctx, options)
 	if err != nil {
-		log.Fatal().Err(err).Msg("error retrieving containers")
+		return nil, d.wrap("list containers", "", err)
 	}
-	return containers
+	return containers, nil
 }
 
-func (d *Docker) ListImages(options types.ImageListOptions) []types.ImageSummary {
+func (d *Docker) ListImages(options types.ImageListOptions) ([]types.ImageSummary, error) {
+	if err := d.reconnect(); err != nil {
+		return nil, err
+	}
 	images, err := d.cli.ImageList(d.ctx, options)
 	if err != nil {
-		log.Fatal().Err(err).Msg("error retrieving images")
+		return nil, d.wrap("list images", "", err)
 	}
-	return images
+	return images, nil
 }
 
-func (d *Docker) ListCompose() map[string][]types.Container {
-	var (
-		filters = filters.NewArgs()
-		stacks  = map[string][]types.Container{}
-	)
+func (d *Docker) ListCompose() (map[string][]types.Container, error) {
+	filters := filters.NewArgs()
 	filters.Add("label", constants.ComposeLabel)
-	containers := d.List(types.ContainerListOptions{All: true, Filters: filters})
-	for _, container := range containers {
-		stacks[container.Labels[constants.ComposeLabel]] = append(stacks[container.Labels[constants.ComposeLabel]], container)
+	containers, err := d.List(types.ContainerListOptions{All: true, Filters: filters})
+	if err != nil {
+		return nil, err
 	}
-	return stacks
+	return groupByStack(containers), nil
 }
 
 func (d *Docker) Stop(containerID string) error {
-	timeout := time.Until(time.Now().Add(30 * time.Second))
-	if err := d.cli.ContainerStop(d.ctx, containerID, &timeout); err != nil {
-		log.Fatal().Str("containerID", containerID).Err(err).Msg("error stoping container")
+	if err := d.reconnect(); err != nil {
 		return err
 	}
-	return nil
+	timeout := time.Until(time.Now().Add(30 * time.Second))
+	return d.wrap("stop", containerID, d.cli.ContainerStop(d.ctx, containerID, &timeout))
 }
 
 func (d *Docker) Start(containerID string) error {
-	if err := d.cli.ContainerStart(d.ctx, containerID, types.ContainerStartOptions{}); err != nil {
-		log.Fatal().Str("containerID", containerID).Err(err).Msg("error starting container")
+	if err := d.reconnect(); err != nil {
 		return err
 	}
-	return nil
+	return d.wrap("start", containerID, d.cli.ContainerStart(d.ctx, containerID, types.ContainerStartOptions{}))
 }
 
-func (d *Docker) Inspect(containerID string) (*types.ContainerJSON, error) {
-	container, err := d.cli.ContainerInspect(d.ctx, containerID)
-	if err != nil {
-		log.Fatal().Str("containerID", containerID).Err(err).Msg("error inspecting container")
-		return nil, err
+func (d *Docker) Restart(containerID string) error {
+	if err := d.reconnect(); err != nil {
+		return err
 	}
-	return &container, nil
+	timeout := 30 * time.Second
+	return d.wrap("restart", containerID, d.cli.ContainerRestart(d.ctx, containerID, &timeout))
 }
 
-func (d *Docker) Logs(containerID string, tail string) ([]string, error) {
-	var (
-		bytes = make([]byte, 3000) // Telegram message length limit
-		logs  []string
-	)
-	if tail != "all" && !utils.IsNumber(tail) {
-		tail = "10"
+func (d *Docker) Pause(containerID string) error {
+	if err := d.reconnect(); err != nil {
+		return err
 	}
-	logsReader, err := d.cli.ContainerLogs(d.ctx, containerID, types.ContainerLogsOptions{Tail: tail, ShowStderr: true, ShowStdout: true})
-	if err != nil {
-		log.Fatal().Str("containerID", containerID).Err(err).Msg("error getting container logs")
-		return nil, err
+	return d.wrap("pause", containerID, d.cli.ContainerPause(d.ctx, containerID))
+}
+
+func (d *Docker) Unpause(containerID string) error {
+	if err := d.reconnect(); err != nil {
+		return err
 	}
-	defer func() {
-		err := logsReader.Close()
-		if err != nil {
-			log.Fatal().Str("containerID", containerID).Err(err).Msg("error closing io.Reader")
-		}
-	}()
+	return d.wrap("unpause", containerID, d.cli.ContainerUnpause(d.ctx, containerID))
+}
 
-	for {
-		numBytes, err := logsReader.Read(bytes)
-		logs = append(logs, string(bytes[:numBytes]))
-		if err == io.EOF {
-			break
-		}
+// Kill sends signal to the container, SIGKILL when signal is empty.
+func (d *Docker) Kill(containerID string, signal string) error {
+	if err := d.reconnect(); err != nil {
+		return err
 	}
-	return logs, nil
+	if signal == "" {
+		signal = "SIGKILL"
+	}
+	return d.wrap("kill", containerID, d.cli.ContainerKill(d.ctx, containerID, signal))
+}
+
+// SetRestartPolicy changes the restart policy of a container, one of RestartPolicies.
+func (d *Docker) SetRestartPolicy(containerID string, policy string) error {
+	if !contains(RestartPolicies, policy) {
+		return &Error{Op: "update", ContainerID: containerID, Err: fmt.Errorf("invalid restart policy %q", policy)}
+	}
+	if err := d.reconnect(); err != nil {
+		return err
+	}
+	_, err := d.cli.ContainerUpdate(d.ctx, containerID, container.UpdateConfig{RestartPolicy: container.RestartPolicy{Name: policy}})
+	return d.wrap("update", containerID, err)
+}
+
+func (d *Docker) Inspect(containerID string) (*types.ContainerJSON, error) {
+	if err := d.reconnect(); err != nil {
+		return nil, err
+	}
+	container, err := d.cli.ContainerInspect(d.ctx, containerID)
+	if err != nil {
+		return nil, d.wrap("inspect", containerID, err)
+	}
+	return &container, nil
 }
 
 func (d *Docker) IsValidID(containerID string) bool {
+	return isValidID(containerID)
+}
+
+func isValidID(containerID string) bool {
 	re := regexp.MustCompile(`(?m)^[A-Fa-f0-9]{10,12}$`)
 	return re.MatchString(containerID)
 }
@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/mrmarble/teledock/internal/constants"
+)
+
+// ContainerEngine is the set of container operations the bot relies on.
+// Docker implements it against a real daemon and Fake keeps everything in memory.
+// Failed operations return an *Error whose kind can be checked with errors.Is.
+type ContainerEngine interface {
+	Ping() error
+	List(options types.ContainerListOptions) ([]types.Container, error)
+	ListImages(options types.ImageListOptions) ([]types.ImageSummary, error)
+	ListCompose() (map[string][]types.Container, error)
+	Stop(containerID string) error
+	Start(containerID string) error
+	Restart(containerID string) error
+	Pause(containerID string) error
+	Unpause(containerID string) error
+	Kill(containerID string, signal string) error
+	SetRestartPolicy(containerID string, policy string) error
+	Inspect(containerID string) (*types.ContainerJSON, error)
+	Stats(containerID string) (*Stats, error)
+	Logs(containerID string, options LogsOptions) ([]LogLine, error)
+	FollowLogs(ctx context.Context, containerID string) (<-chan LogLine, error)
+	Exec(containerID string, cmd []string, timeout time.Duration) (*ExecResult, error)
+	Shell(containerID string, cmd []string) (*ShellSession, error)
+	Create(spec ContainerSpec) (string, error)
+	Events(ctx context.Context) (<-chan Event, error)
+	IsValidID(containerID string) bool
+	IsSelf(containerID string) bool
+}
+
+var _ ContainerEngine = (*Docker)(nil)
+var _ ContainerEngine = (*Fake)(nil)
+
+// groupByStack groups containers by their compose project label.
+func groupByStack(containers []types.Container) map[string][]types.Container {
+	stacks := map[string][]types.Container{}
+	for _, container := range containers {
+		stack, ok := container.Labels[constants.ComposeLabel]
+		if !ok {
+			continue
+		}
+		stacks[stack] = append(stacks[stack], container)
+	}
+	return stacks
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
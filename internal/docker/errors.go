@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// Error kinds returned by the engine. Use errors.Is to tell them apart.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrUnavailable      = errors.New("docker daemon unreachable")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrTimeout          = errors.New("timed out")
+)
+
+// Error describes a failed engine operation.
+type Error struct {
+	Op          string
+	ContainerID string
+	// Kind is one of the Err* sentinels, nil when the failure is not classified.
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	target := e.Op
+	if e.ContainerID != "" {
+		target = fmt.Sprintf("%v %v", e.Op, e.ContainerID)
+	}
+	if e.Err == nil {
+		return fmt.Sprintf("%v: %v", target, e.Kind)
+	}
+	return fmt.Sprintf("%v: %v", target, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of e.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// newError classifies err and wraps it into an Error.
+func newError(op, containerID string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: op, ContainerID: containerID, Kind: kindOf(err), Err: err}
+}
+
+func kindOf(err error) error {
+	switch {
+	case errdefs.IsNotFound(err):
+		return ErrNotFound
+	case errdefs.IsConflict(err), errdefs.IsNotModified(err):
+		return ErrConflict
+	case errdefs.IsUnauthorized(err), errdefs.IsForbidden(err), strings.Contains(err.Error(), "permission denied"):
+		return ErrPermissionDenied
+	case errdefs.IsDeadline(err), errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	case client.IsErrConnectionFailed(err), errdefs.IsUnavailable(err), strings.Contains(err.Error(), "connection refused"):
+		return ErrUnavailable
+	}
+	return nil
+}
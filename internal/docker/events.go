@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Container event actions, health checks are reported as EventHealthy and EventUnhealthy.
+const (
+	EventStart     = "start"
+	EventStop      = "stop"
+	EventRestart   = "restart"
+	EventDie       = "die"
+	EventKill      = "kill"
+	EventOOM       = "oom"
+	EventHealthy   = "healthy"
+	EventUnhealthy = "unhealthy"
+)
+
+// Event is something that happened to a container.
+type Event struct {
+	Action      string
+	ContainerID string
+	Name        string
+	Image       string
+	Labels      map[string]string
+	// ExitCode is set on EventDie.
+	ExitCode string
+	// Signal is the number of the signal sent on EventKill.
+	Signal string
+	Time   time.Time
+}
+
+// newEvent converts a docker container event, the attributes of the actor hold
+// the container labels along its name, image, exit code and signal.
+func newEvent(message events.Message) Event {
+	labels := map[string]string{}
+	for key, value := range message.Actor.Attributes {
+		switch key {
+		case "name", "image", "exitCode", "signal":
+		default:
+			labels[key] = value
+		}
+	}
+	action := message.Action
+	if strings.HasPrefix(action, "health_status: ") {
+		action = strings.TrimPrefix(action, "health_status: ")
+	}
+	return Event{
+		Action:      action,
+		ContainerID: message.Actor.ID,
+		Name:        message.Actor.Attributes["name"],
+		Image:       message.Actor.Attributes["image"],
+		Labels:      labels,
+		ExitCode:    message.Actor.Attributes["exitCode"],
+		Signal:      message.Actor.Attributes["signal"],
+		Time:        time.Unix(0, message.TimeNano),
+	}
+}
+
+// Events streams container events until ctx is cancelled or the connection to
+// the daemon is lost, then the channel is closed.
+func (d *Docker) Events(ctx context.Context) (<-chan Event, error) {
+	if err := d.reconnect(); err != nil {
+		return nil, err
+	}
+
+	filters := filters.NewArgs()
+	filters.Add("type", events.ContainerEventType)
+	messages, errs := d.cli.Events(ctx, types.EventsOptions{Filters: filters})
+
+	stream := make(chan Event, 100)
+	go func() {
+		defer close(stream)
+		for {
+			select {
+			case message := <-messages:
+				select {
+				case stream <- newEvent(message):
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				if ctx.Err() == nil {
+					_ = d.wrap("events", "", err)
+				}
+				return
+			}
+		}
+	}()
+	return stream, nil
+}
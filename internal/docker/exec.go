@@ -0,0 +1,148 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	// execOutputLimit caps how much of each output stream Exec keeps, in bytes.
+	execOutputLimit = 1 << 20
+	// execInspectTimeout bounds reading the exit code once the command is done.
+	execInspectTimeout = 10 * time.Second
+)
+
+// ExecResult holds the outcome of a command run inside a container.
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Truncated is set when the output went over execOutputLimit and the rest was dropped.
+	Truncated bool
+}
+
+// cappedBuffer keeps the first limit bytes written to it and silently drops the
+// rest, so a command can't fill the memory of the bot.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// Exec runs cmd inside the container and waits for it to finish, at most for timeout.
+// On timeout it returns ErrTimeout and detaches, the docker API can't stop an exec
+// so the command keeps running inside the container until it exits on its own.
+func (d *Docker) Exec(containerID string, cmd []string, timeout time.Duration) (*ExecResult, error) {
+	if err := d.reconnect(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(d.ctx, timeout)
+	defer cancel()
+
+	exec, err := d.cli.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, d.wrap("exec", containerID, err)
+	}
+
+	attach, err := d.cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, d.wrap("exec", containerID, err)
+	}
+	defer attach.Close()
+
+	stdout, stderr := &cappedBuffer{limit: execOutputLimit}, &cappedBuffer{limit: execOutputLimit}
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, attach.Reader)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			return nil, d.wrap("exec", containerID, err)
+		}
+	case <-ctx.Done():
+		attach.Close()
+		return nil, &Error{Op: "exec", ContainerID: containerID, Kind: ErrTimeout, Err: ctx.Err()}
+	}
+
+	// The command may have used most of ctx, the exit code gets a deadline of its own.
+	inspectCtx, inspectCancel := context.WithTimeout(d.ctx, execInspectTimeout)
+	defer inspectCancel()
+	inspect, err := d.cli.ContainerExecInspect(inspectCtx, exec.ID)
+	if err != nil {
+		return nil, d.wrap("exec", containerID, err)
+	}
+
+	return &ExecResult{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		ExitCode:  inspect.ExitCode,
+		Truncated: stdout.truncated || stderr.truncated,
+	}, nil
+}
+
+// ShellSession is an interactive exec attached to a TTY inside a container.
+type ShellSession struct {
+	// Stdin receives the input typed by the user.
+	Stdin io.Writer
+	// Output streams everything the TTY prints, stdout and stderr are not separated.
+	Output io.Reader
+	closer io.Closer
+}
+
+// Close terminates the session.
+func (s *ShellSession) Close() error {
+	return s.closer.Close()
+}
+
+// Shell opens an interactive TTY exec running cmd inside the container.
+func (d *Docker) Shell(containerID string, cmd []string) (*ShellSession, error) {
+	if err := d.reconnect(); err != nil {
+		return nil, err
+	}
+	exec, err := d.cli.ContainerExecCreate(d.ctx, containerID, types.ExecConfig{
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          []string{"TERM=dumb"},
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, d.wrap("shell", containerID, err)
+	}
+
+	attach, err := d.cli.ContainerExecAttach(d.ctx, exec.ID, types.ExecStartCheck{Tty: true})
+	if err != nil {
+		return nil, d.wrap("shell", containerID, err)
+	}
+
+	// A wide terminal keeps the shell from wrapping lines, telegram does it already.
+	if err := d.cli.ContainerExecResize(d.ctx, exec.ID, types.ResizeOptions{Height: 24, Width: 200}); err != nil {
+		log.Debug().Str("containerID", containerID).Err(err).Msg("error resizing shell")
+	}
+
+	return &ShellSession{Stdin: attach.Conn, Output: attach.Reader, closer: attach.Conn}, nil
+}
@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []string
+		want      string
+		truncated bool
+	}{
+		{"under the limit", []string{"ab", "cd"}, "abcd", false},
+		{"at the limit", []string{"abcde"}, "abcde", false},
+		{"over the limit", []string{"abc", "defg"}, "abcde", true},
+		{"full", []string{"abcde", "f"}, "abcde", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &cappedBuffer{limit: 5}
+			for _, write := range tt.writes {
+				if n, err := buffer.Write([]byte(write)); err != nil || n != len(write) {
+					t.Fatalf("Write(%q) = %v, %v, want %v, nil", write, n, err, len(write))
+				}
+			}
+			if buffer.String() != tt.want || buffer.truncated != tt.truncated {
+				t.Errorf("buffer = %q truncated %v, want %q truncated %v", buffer.String(), buffer.truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestCappedBufferLimit(t *testing.T) {
+	buffer := &cappedBuffer{limit: execOutputLimit}
+	_, _ = buffer.Write([]byte(strings.Repeat("x", execOutputLimit+1)))
+	if buffer.Len() != execOutputLimit || !buffer.truncated {
+		t.Errorf("buffer kept %v bytes truncated %v, want %v truncated", buffer.Len(), buffer.truncated, execOutputLimit)
+	}
+}
@@ -0,0 +1,419 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/mrmarble/teledock/internal/constants"
+)
+
+// Fake is an in-memory ContainerEngine with scripted containers, images and logs.
+// It is meant to drive the telegram handlers without a docker daemon.
+type Fake struct {
+	mu sync.Mutex
+
+	Containers []types.Container
+	Images     []types.ImageSummary
+	// ContainerLogs holds the log lines returned by Logs, keyed by full container ID.
+	ContainerLogs map[string][]LogLine
+	// ContainerStats holds the samples returned by Stats, keyed by full container ID.
+	// Containers without one report no usage.
+	ContainerStats map[string]*Stats
+	// ExecResults holds the result returned by Exec, keyed by the space joined command.
+	// Unknown commands are echoed back on stdout, commands with a nil result time out.
+	ExecResults map[string]*ExecResult
+	// Calls records every mutating call as "method:containerID".
+	Calls []string
+	// Self is the ID of the container the bot pretends to run in.
+	Self string
+	// Down simulates an unreachable daemon, every call fails with ErrUnavailable.
+	Down bool
+
+	subscribers []chan Event
+}
+
+// NewFake returns an empty Fake engine.
+func NewFake() *Fake {
+	return &Fake{ContainerLogs: map[string][]LogLine{}, ContainerStats: map[string]*Stats{}, ExecResults: map[string]*ExecResult{}}
+}
+
+// AddContainer registers a container. The ID is padded to 64 characters.
+func (f *Fake) AddContainer(id, name, image, state string, labels map[string]string) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	f.Containers = append(f.Containers, types.Container{
+		ID:     padID(id),
+		Names:  []string{"/" + name},
+		Image:  image,
+		State:  state,
+		Status: state,
+		Labels: labels,
+	})
+	return f
+}
+
+// AddStack registers a compose stack made of the given services, all running.
+func (f *Fake) AddStack(stack string, services ...string) *Fake {
+	for _, service := range services {
+		f.AddContainer(fmt.Sprintf("%x", stack+"_"+service), fmt.Sprintf("%v_%v_1", stack, service), service, "running", map[string]string{
+			constants.ComposeLabel:        stack,
+			constants.ComposeServiceLabel: service,
+		})
+	}
+	return f
+}
+
+// AddImage registers an image with the given tag.
+func (f *Fake) AddImage(id, tag string) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Images = append(f.Images, types.ImageSummary{
+		ID:       "sha256:" + padID(id),
+		RepoTags: []string{tag},
+	})
+	return f
+}
+
+func (f *Fake) Ping() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.check("ping")
+}
+
+func (f *Fake) List(options types.ContainerListOptions) ([]types.Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.check("list containers"); err != nil {
+		return nil, err
+	}
+	containers := []types.Container{}
+	for _, container := range f.Containers {
+		if !options.All && container.State != "running" {
+			continue
+		}
+		if options.Filters.Contains("status") && !options.Filters.ExactMatch("status", container.State) {
+			continue
+		}
+		if options.Filters.Contains("label") && !options.Filters.MatchKVList("label", container.Labels) {
+			continue
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+func (f *Fake) ListImages(options types.ImageListOptions) ([]types.ImageSummary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.check("list images"); err != nil {
+		return nil, err
+	}
+	return append([]types.ImageSummary{}, f.Images...), nil
+}
+
+func (f *Fake) ListCompose() (map[string][]types.Container, error) {
+	containers, err := f.List(types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	return groupByStack(containers), nil
+}
+
+func (f *Fake) Stop(containerID string) error {
+	return f.setState("stop", containerID, "exited", "running", "paused", "restarting")
+}
+
+func (f *Fake) Start(containerID string) error {
+	return f.setState("start", containerID, "running", "created", "exited")
+}
+
+func (f *Fake) Restart(containerID string) error {
+	return f.setState("restart", containerID, "running")
+}
+
+func (f *Fake) Pause(containerID string) error {
+	return f.setState("pause", containerID, "paused", "running")
+}
+
+func (f *Fake) Unpause(containerID string) error {
+	return f.setState("unpause", containerID, "running", "paused")
+}
+
+func (f *Fake) Kill(containerID string, signal string) error {
+	return f.setState("kill", containerID, "exited", "running")
+}
+
+// SetRestartPolicy only records the call, Fake containers have no restart policy.
+func (f *Fake) SetRestartPolicy(containerID string, policy string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !contains(RestartPolicies, policy) {
+		return &Error{Op: "update", ContainerID: containerID, Err: fmt.Errorf("invalid restart policy %q", policy)}
+	}
+	container, err := f.find("update", containerID)
+	if err != nil {
+		return err
+	}
+	f.Calls = append(f.Calls, fmt.Sprintf("update:%v", container.ID))
+	return nil
+}
+
+func (f *Fake) Inspect(containerID string) (*types.ContainerJSON, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	found, err := f.find("inspect", containerID)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:    found.ID,
+			Name:  found.Names[0],
+			Image: found.Image,
+			State: &types.ContainerState{Status: found.State, Running: found.State == "running"},
+		},
+		Config: &container.Config{Image: found.Image, Labels: found.Labels},
+	}, nil
+}
+
+func (f *Fake) Stats(containerID string) (*Stats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	container, err := f.find("stats", containerID)
+	if err != nil {
+		return nil, err
+	}
+	stats := Stats{}
+	if scripted, ok := f.ContainerStats[container.ID]; ok {
+		stats = *scripted
+	}
+	stats.ContainerID, stats.Name, stats.Time = container.ID, container.Names[0][1:], time.Now()
+	return &stats, nil
+}
+
+func (f *Fake) Logs(containerID string, options LogsOptions) ([]LogLine, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	container, err := f.find("logs", containerID)
+	if err != nil {
+		return nil, err
+	}
+	logsOptions := options.containerLogsOptions()
+	lines := []LogLine{}
+	for _, line := range f.ContainerLogs[container.ID] {
+		if (line.Stream == Stdout && logsOptions.ShowStdout) || (line.Stream == Stderr && logsOptions.ShowStderr) {
+			lines = options.add(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// FollowLogs streams the scripted log lines and then waits for ctx to be cancelled.
+func (f *Fake) FollowLogs(ctx context.Context, containerID string) (<-chan LogLine, error) {
+	logs, err := f.Logs(containerID, LogsOptions{Tail: "10"})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(chan LogLine)
+	go func() {
+		defer close(lines)
+		for _, line := range logs {
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return lines, nil
+}
+
+func (f *Fake) Exec(containerID string, cmd []string, timeout time.Duration) (*ExecResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	container, err := f.find("exec", containerID)
+	if err != nil {
+		return nil, err
+	}
+	if container.State != "running" {
+		return nil, &Error{Op: "exec", ContainerID: containerID, Kind: ErrConflict, Err: fmt.Errorf("container is %v", container.State)}
+	}
+	f.Calls = append(f.Calls, fmt.Sprintf("exec:%v", container.ID))
+
+	command := strings.Join(cmd, " ")
+	if result, ok := f.ExecResults[command]; ok {
+		if result == nil {
+			return nil, &Error{Op: "exec", ContainerID: containerID, Kind: ErrTimeout, Err: context.DeadlineExceeded}
+		}
+		return result, nil
+	}
+	return &ExecResult{Stdout: command + "\n"}, nil
+}
+
+func (f *Fake) Create(spec ContainerSpec) (string, error) {
+	if err := spec.Validate(); err != nil {
+		return "", &Error{Op: "create", Err: err}
+	}
+
+	f.mu.Lock()
+	for _, container := range f.Containers {
+		if spec.Name != "" && container.Names[0] == "/"+spec.Name {
+			f.mu.Unlock()
+			return "", &Error{Op: "create", ContainerID: spec.Name, Kind: ErrConflict, Err: fmt.Errorf("name %v is already in use", spec.Name)}
+		}
+	}
+	id := fmt.Sprintf("%x", len(f.Containers)+1)
+	f.mu.Unlock()
+
+	name := spec.Name
+	if name == "" {
+		name = "container_" + id
+	}
+	f.AddContainer(id, name, spec.Image, "created", nil)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, fmt.Sprintf("create:%v", padID(id)))
+	return padID(id), nil
+}
+
+// Events streams the events sent with Emit until ctx is cancelled.
+func (f *Fake) Events(ctx context.Context) (<-chan Event, error) {
+	f.mu.Lock()
+	if err := f.check("events"); err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	stream := make(chan Event, 100)
+	f.subscribers = append(f.subscribers, stream)
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for index, subscriber := range f.subscribers {
+			if subscriber == stream {
+				f.subscribers = append(f.subscribers[:index], f.subscribers[index+1:]...)
+				break
+			}
+		}
+		close(stream)
+	}()
+	return stream, nil
+}
+
+// Emit sends event to every Events stream, filling its name, image and labels
+// from the container when it is registered.
+func (f *Fake) Emit(event Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if container, err := f.find("events", event.ContainerID); err == nil {
+		event.ContainerID, event.Name, event.Image, event.Labels = container.ID, container.Names[0][1:], container.Image, container.Labels
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, subscriber := range f.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Shell opens a session that echoes back its input, like cat.
+func (f *Fake) Shell(containerID string, cmd []string) (*ShellSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	container, err := f.find("shell", containerID)
+	if err != nil {
+		return nil, err
+	}
+	f.Calls = append(f.Calls, fmt.Sprintf("shell:%v", container.ID))
+
+	reader, writer := io.Pipe()
+	return &ShellSession{Stdin: writer, Output: reader, closer: writer}, nil
+}
+
+func (f *Fake) IsValidID(containerID string) bool {
+	return isValidID(containerID)
+}
+
+// setState moves a container to state, failing with ErrConflict unless it is in one of the from states.
+// Any state is accepted when from is empty.
+func (f *Fake) setState(method, containerID, state string, from ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	container, err := f.find(method, containerID)
+	if err != nil {
+		return err
+	}
+	if len(from) > 0 && !contains(from, container.State) {
+		return &Error{Op: method, ContainerID: containerID, Kind: ErrConflict, Err: fmt.Errorf("container is %v", container.State)}
+	}
+	container.State = state
+	container.Status = state
+	f.Calls = append(f.Calls, fmt.Sprintf("%v:%v", method, container.ID))
+	return nil
+}
+
+func (f *Fake) IsSelf(containerID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return isSelf(f.Self, containerID)
+}
+
+// padID right pads id with zeros up to the 64 characters of a real docker ID.
+func padID(id string) string {
+	if len(id) >= 64 {
+		return id[:64]
+	}
+	return id + strings.Repeat("0", 64-len(id))
+}
+
+// check fails when the fake daemon is down. Callers must hold f.mu.
+func (f *Fake) check(op string) error {
+	if f.Down {
+		return &Error{Op: op, Kind: ErrUnavailable}
+	}
+	return nil
+}
+
+// find returns the container whose ID starts with containerID. Callers must hold f.mu.
+func (f *Fake) find(op, containerID string) (*types.Container, error) {
+	if err := f.check(op); err != nil {
+		return nil, err
+	}
+	for index := range f.Containers {
+		if containerID != "" && strings.HasPrefix(f.Containers[index].ID, containerID) {
+			return &f.Containers[index], nil
+		}
+	}
+	return nil, &Error{Op: op, ContainerID: containerID, Kind: ErrNotFound, Err: fmt.Errorf("no such container: %v", containerID)}
+}
@@ -0,0 +1,193 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/mrmarble/teledock/internal/utils"
+)
+
+// Log streams.
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// LogLine is a line of container output.
+type LogLine struct {
+	// Stream is Stdout or Stderr. Containers with a TTY only have Stdout.
+	Stream string
+	Text   string
+}
+
+// LogsOptions selects the log lines returned by Logs.
+type LogsOptions struct {
+	// Tail is the number of lines to show from the end, or "all". With Grep it
+	// counts the matching lines, the filter is applied first.
+	Tail string
+	// Stdout and Stderr filter the streams, both are shown when none is set.
+	Stdout bool
+	Stderr bool
+	// Since and Until bound the logs by time, either as a timestamp or as a duration
+	// relative to now like "1h".
+	Since string
+	Until string
+	// Timestamps prefixes every line with the time it was logged.
+	Timestamps bool
+	// Grep keeps only the lines matching it, when set.
+	Grep *regexp.Regexp
+}
+
+func (o LogsOptions) containerLogsOptions() types.ContainerLogsOptions {
+	tail := o.tail()
+	// The daemon can't filter, the tail is taken from the matching lines by add.
+	if o.Grep != nil {
+		tail = "all"
+	}
+	return types.ContainerLogsOptions{
+		Tail:       tail,
+		ShowStdout: o.Stdout || !o.Stderr,
+		ShowStderr: o.Stderr || !o.Stdout,
+		Since:      o.Since,
+		Until:      o.Until,
+		Timestamps: o.Timestamps,
+	}
+}
+
+// tail returns Tail, defaulting to the last 10 lines when it is not valid.
+func (o LogsOptions) tail() string {
+	if o.Tail != "all" && !utils.IsNumber(o.Tail) {
+		return "10"
+	}
+	return o.Tail
+}
+
+// add appends line to lines when it passes the Grep filter, keeping only the
+// last Tail lines.
+func (o LogsOptions) add(lines []LogLine, line LogLine) []LogLine {
+	if o.Grep != nil && !o.Grep.MatchString(line.Text) {
+		return lines
+	}
+	lines = append(lines, line)
+	if tail, err := strconv.Atoi(o.tail()); err == nil && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	return lines
+}
+
+func (d *Docker) Logs(containerID string, options LogsOptions) ([]LogLine, error) {
+	container, err := d.Inspect(containerID)
+	if err != nil {
+		return nil, err
+	}
+	logsReader, err := d.cli.ContainerLogs(d.ctx, containerID, options.containerLogsOptions())
+	if err != nil {
+		return nil, d.wrap("logs", containerID, err)
+	}
+	defer func() {
+		err := logsReader.Close()
+		if err != nil {
+			log.Error().Str("containerID", containerID).Err(err).Msg("error closing io.Reader")
+		}
+	}()
+
+	lines := []LogLine{}
+	err = demux(logsReader, container.Config != nil && container.Config.Tty, func(line LogLine) error {
+		lines = options.add(lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, d.wrap("logs", containerID, err)
+	}
+	return lines, nil
+}
+
+// FollowLogs streams the log lines of a container, starting from the last 10,
+// until ctx is cancelled or the container stops.
+func (d *Docker) FollowLogs(ctx context.Context, containerID string) (<-chan LogLine, error) {
+	container, err := d.Inspect(containerID)
+	if err != nil {
+		return nil, err
+	}
+	options := LogsOptions{Tail: "10"}.containerLogsOptions()
+	options.Follow = true
+	logsReader, err := d.cli.ContainerLogs(ctx, containerID, options)
+	if err != nil {
+		return nil, d.wrap("logs", containerID, err)
+	}
+
+	lines := make(chan LogLine, 100)
+	go func() {
+		defer close(lines)
+		defer logsReader.Close()
+		_ = demux(logsReader, container.Config != nil && container.Config.Tty, func(line LogLine) error {
+			select {
+			case lines <- line:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return lines, nil
+}
+
+// demux splits a log stream into lines and hands them to emit. Streams of containers
+// without a TTY are multiplexed with 8 byte frame headers telling stdout from stderr,
+// streams of containers with a TTY are raw and all stdout.
+func demux(reader io.Reader, tty bool, emit func(LogLine) error) error {
+	stdout := &lineWriter{stream: Stdout, emit: emit}
+	stderr := &lineWriter{stream: Stderr, emit: emit}
+
+	var err error
+	if tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	}
+	if err != nil {
+		return err
+	}
+	if err = stdout.flush(); err != nil {
+		return err
+	}
+	return stderr.flush()
+}
+
+// lineWriter emits every complete line written to it.
+type lineWriter struct {
+	stream string
+	buffer []byte
+	emit   func(LogLine) error
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buffer = append(w.buffer, p...)
+	for {
+		index := bytes.IndexByte(w.buffer, '\n')
+		if index < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimSuffix(string(w.buffer[:index]), "\r")
+		w.buffer = w.buffer[index+1:]
+		if err := w.emit(LogLine{Stream: w.stream, Text: line}); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// flush emits the last line if it did not end with a new line.
+func (w *lineWriter) flush() error {
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	line := strings.TrimSuffix(string(w.buffer), "\r")
+	w.buffer = nil
+	return w.emit(LogLine{Stream: w.stream, Text: line})
+}
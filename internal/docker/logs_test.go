@@ -0,0 +1,166 @@
+package docker
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// multiplex frames chunks the way the daemon does for containers without a TTY.
+func multiplex(t *testing.T, chunks ...LogLine) *bytes.Buffer {
+	t.Helper()
+	var buffer bytes.Buffer
+	stdout := stdcopy.NewStdWriter(&buffer, stdcopy.Stdout)
+	stderr := stdcopy.NewStdWriter(&buffer, stdcopy.Stderr)
+	for _, chunk := range chunks {
+		writer := stdout
+		if chunk.Stream == Stderr {
+			writer = stderr
+		}
+		if _, err := writer.Write([]byte(chunk.Text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buffer
+}
+
+func TestDemux(t *testing.T) {
+	tests := []struct {
+		name   string
+		tty    bool
+		chunks []LogLine
+		raw    string
+		want   []LogLine
+	}{
+		{
+			name:   "multiplexed",
+			chunks: []LogLine{{Stdout, "one\ntwo\n"}, {Stderr, "oops\n"}},
+			want:   []LogLine{{Stdout, "one"}, {Stdout, "two"}, {Stderr, "oops"}},
+		},
+		{
+			name:   "lines split across frames",
+			chunks: []LogLine{{Stdout, "hel"}, {Stdout, "lo\nwor"}, {Stdout, "ld"}},
+			want:   []LogLine{{Stdout, "hello"}, {Stdout, "world"}},
+		},
+		{
+			name:   "carriage returns",
+			chunks: []LogLine{{Stdout, "dos\r\n"}},
+			want:   []LogLine{{Stdout, "dos"}},
+		},
+		{
+			name: "tty",
+			tty:  true,
+			raw:  "first\nsecond\r\nlast",
+			want: []LogLine{{Stdout, "first"}, {Stdout, "second"}, {Stdout, "last"}},
+		},
+		{
+			name: "empty",
+			tty:  true,
+			want: []LogLine{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bytes.NewBufferString(tt.raw)
+			if !tt.tty {
+				reader = multiplex(t, tt.chunks...)
+			}
+			got := []LogLine{}
+			err := demux(reader, tt.tty, func(line LogLine) error {
+				got = append(got, line)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("demux() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("demux() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineWriterStopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	emitted := 0
+	writer := &lineWriter{stream: Stdout, emit: func(line LogLine) error {
+		emitted++
+		return stop
+	}}
+	if _, err := writer.Write([]byte("one\ntwo\n")); err != stop {
+		t.Errorf("Write() error = %v, want %v", err, stop)
+	}
+	if emitted != 1 {
+		t.Errorf("emitted %v lines after an error, want 1", emitted)
+	}
+}
+
+func TestLogsOptionsAdd(t *testing.T) {
+	lines := []LogLine{{Stdout, "foo 1"}, {Stdout, "bar"}, {Stderr, "foo 2"}, {Stdout, "foo 3"}, {Stdout, "baz"}}
+	tests := []struct {
+		name    string
+		options LogsOptions
+		want    []string
+		daemon  string
+	}{
+		{"tail", LogsOptions{Tail: "2"}, []string{"foo 3", "baz"}, "2"},
+		{"grep then tail", LogsOptions{Tail: "2", Grep: regexp.MustCompile("foo")}, []string{"foo 2", "foo 3"}, "all"},
+		{"all", LogsOptions{Tail: "all"}, []string{"foo 1", "bar", "foo 2", "foo 3", "baz"}, "all"},
+		{"invalid tail", LogsOptions{Tail: "lots"}, []string{"foo 1", "bar", "foo 2", "foo 3", "baz"}, "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := []LogLine{}
+			for _, line := range lines {
+				kept = tt.options.add(kept, line)
+			}
+			got := []string{}
+			for _, line := range kept {
+				got = append(got, line.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("add() kept %q, want %q", got, tt.want)
+			}
+			if tail := tt.options.containerLogsOptions().Tail; tail != tt.daemon {
+				t.Errorf("daemon tail = %q, want %q", tail, tt.daemon)
+			}
+		})
+	}
+}
+
+func TestFakeLogsGrepsBeforeTail(t *testing.T) {
+	fake := NewFake().AddContainer("abc", "web", "nginx", "running", nil)
+	fake.ContainerLogs[padID("abc")] = []LogLine{
+		{Stdout, "foo 1"}, {Stdout, "bar"}, {Stderr, "foo 2"}, {Stdout, "foo 3"}, {Stdout, "baz"},
+	}
+
+	tests := []struct {
+		name    string
+		options LogsOptions
+		want    []string
+	}{
+		{"tail", LogsOptions{Tail: "2"}, []string{"foo 3", "baz"}},
+		{"grep then tail", LogsOptions{Tail: "2", Grep: regexp.MustCompile("foo")}, []string{"foo 2", "foo 3"}},
+		{"stdout only", LogsOptions{Tail: "all", Stdout: true, Grep: regexp.MustCompile("foo")}, []string{"foo 1", "foo 3"}},
+		{"invalid tail", LogsOptions{Tail: "lots"}, []string{"foo 1", "bar", "foo 2", "foo 3", "baz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := fake.Logs("abc", tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, line := range lines {
+				got = append(got, line.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Logs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// statsRing keeps the last samples of a container, overwriting the oldest.
+type statsRing struct {
+	samples []Stats
+	next    int
+	full    bool
+}
+
+func (r *statsRing) push(sample Stats) {
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// since returns the samples taken after from, oldest first.
+func (r *statsRing) since(from time.Time) []Stats {
+	ordered := r.samples[:r.next]
+	if r.full {
+		ordered = append(append([]Stats{}, r.samples[r.next:]...), r.samples[:r.next]...)
+	}
+	samples := []Stats{}
+	for _, sample := range ordered {
+		if sample.Time.After(from) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+func (r *statsRing) last() Stats {
+	return r.samples[(r.next+len(r.samples)-1)%len(r.samples)]
+}
+
+// Sampler periodically records the resource usage of the running containers,
+// keeping the samples taken within retention.
+type Sampler struct {
+	engine    ContainerEngine
+	interval  time.Duration
+	retention time.Duration
+
+	mu      sync.RWMutex
+	history map[string]*statsRing
+	// callbacks are called with every round of samples.
+	callbacks []func(samples []Stats, running []string)
+}
+
+// NewSampler returns a Sampler taking a sample of every running container each interval.
+func NewSampler(engine ContainerEngine, interval, retention time.Duration) *Sampler {
+	return &Sampler{engine: engine, interval: interval, retention: retention, history: map[string]*statsRing{}}
+}
+
+// Interval is the time between two samples of a container.
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+// Retention is how long samples are kept.
+func (s *Sampler) Retention() time.Duration {
+	return s.retention
+}
+
+// OnSample registers callback to be called with the samples of every running
+// container each interval. running holds the IDs of all the running containers,
+// those whose sample failed too. It has to be called before Run.
+func (s *Sampler) OnSample(callback func(samples []Stats, running []string)) {
+	s.callbacks = append(s.callbacks, callback)
+}
+
+// Run samples the running containers until ctx is done.
+func (s *Sampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sample()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample records the usage of every running container and hands it to the callbacks.
+func (s *Sampler) sample() {
+	containers, err := s.engine.List(types.ContainerListOptions{})
+	if err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	samples := make(chan *Stats, len(containers))
+	running := make([]string, 0, len(containers))
+	for _, container := range containers {
+		running = append(running, container.ID)
+		wg.Add(1)
+		go func(container types.Container) {
+			defer wg.Done()
+			if sample, err := s.engine.Stats(container.ID); err == nil {
+				sample.Labels = container.Labels
+				samples <- sample
+			}
+		}(container)
+	}
+	wg.Wait()
+	close(samples)
+
+	round := s.record(samples)
+	for _, callback := range s.callbacks {
+		callback(round, running)
+	}
+}
+
+// record adds the samples to the history of their containers and returns them,
+// forgetting the containers that have not been sampled within retention.
+func (s *Sampler) record(samples <-chan *Stats) []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	round := []Stats{}
+	size := int(s.retention / s.interval)
+	if size < 1 {
+		size = 1
+	}
+	for sample := range samples {
+		ring, ok := s.history[sample.ContainerID]
+		if !ok {
+			ring = &statsRing{samples: make([]Stats, size)}
+			s.history[sample.ContainerID] = ring
+		}
+		ring.push(*sample)
+		round = append(round, *sample)
+	}
+	for containerID, ring := range s.history {
+		if time.Since(ring.last().Time) > s.retention {
+			delete(s.history, containerID)
+		}
+	}
+	return round
+}
+
+// History returns the samples of a container taken within the last period, oldest
+// first. containerID can be abbreviated.
+func (s *Sampler) History(containerID string, period time.Duration) []Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	from := time.Now().Add(-period)
+	for id, ring := range s.history {
+		if strings.HasPrefix(id, containerID) {
+			return ring.since(from)
+		}
+	}
+	return []Stats{}
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var containerIDRe = regexp.MustCompile(`[a-f0-9]{64}`)
+
+// containerMounts are the files docker bind mounts into a container from the
+// directory of that container, like /var/lib/docker/containers/<id>/hostname.
+var containerMounts = map[string]bool{"/etc/hostname": true, "/etc/hosts": true, "/etc/resolv.conf": true}
+
+// detectSelf returns the ID of the container teledock runs in, or an empty string
+// when it does not run in a container or the ID can't be found.
+func (d *Docker) detectSelf() string {
+	// cgroup v1 and some v2 setups expose the ID in the cgroup path.
+	if id := findContainerID("/proc/self/cgroup", isDockerCgroup); id != "" {
+		return id
+	}
+	// With cgroup v2 namespaces the ID only shows in the bind mounts docker sets up.
+	// On the host every container shows up there too, so only the mounts of the
+	// files docker places in a container are trusted.
+	if id := findContainerID("/proc/self/mountinfo", isContainerMount); id != "" {
+		return id
+	}
+	// Docker sets the hostname to the short container ID unless told otherwise.
+	hostname, err := os.Hostname()
+	if err != nil || !isValidID(hostname) {
+		return ""
+	}
+	container, err := d.cli.ContainerInspect(d.ctx, hostname)
+	if err != nil {
+		return ""
+	}
+	return container.ID
+}
+
+// findContainerID returns the first container ID found in a line of path accepted by match.
+func findContainerID(path string, match func(line string) bool) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !match(line) {
+			continue
+		}
+		if id := containerIDRe.FindString(line); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func isDockerCgroup(line string) bool {
+	return strings.Contains(line, "docker")
+}
+
+// isContainerMount reports whether a mountinfo line mounts a file of a container
+// directory on one of containerMounts. Its fifth field is the mount point.
+func isContainerMount(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 4 && strings.Contains(fields[3], "/containers/") && containerMounts[fields[4]]
+}
+
+// IsSelf reports whether containerID, full or abbreviated, is the container teledock runs in.
+func (d *Docker) IsSelf(containerID string) bool {
+	return isSelf(d.selfID, containerID)
+}
+
+func isSelf(selfID, containerID string) bool {
+	return selfID != "" && containerID != "" && strings.HasPrefix(selfID, containerID)
+}
@@ -0,0 +1,101 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindContainerIDInMountinfo(t *testing.T) {
+	self := strings.Repeat("a", 64)
+	other := strings.Repeat("b", 64)
+	tests := []struct {
+		name      string
+		mountinfo []string
+		want      string
+	}{
+		{
+			name: "inside a container",
+			mountinfo: []string{
+				"600 500 0:50 / / rw,relatime - overlay overlay rw",
+				"610 600 8:1 /var/lib/docker/containers/" + self + "/resolv.conf /etc/resolv.conf rw - ext4 /dev/sda1 rw",
+				"611 600 8:1 /var/lib/docker/containers/" + self + "/hostname /etc/hostname rw - ext4 /dev/sda1 rw",
+			},
+			want: self,
+		},
+		{
+			name: "on the host",
+			mountinfo: []string{
+				"22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw",
+				"700 22 0:60 / /var/lib/docker/containers/" + other + "/mounts/shm rw - tmpfs shm rw",
+			},
+			want: "",
+		},
+		{
+			name: "container directory mounted elsewhere",
+			mountinfo: []string{
+				"600 500 0:50 / / rw,relatime - overlay overlay rw",
+				"610 600 8:1 /var/lib/docker/containers/" + other + "/hostname /data/hostname rw - ext4 /dev/sda1 rw",
+				"611 600 8:1 /var/lib/docker/containers/" + self + "/hosts /etc/hosts rw - ext4 /dev/sda1 rw",
+			},
+			want: self,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mountinfo")
+			if err := os.WriteFile(path, []byte(strings.Join(tt.mountinfo, "\n")), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if got := findContainerID(path, isContainerMount); got != tt.want {
+				t.Errorf("findContainerID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindContainerIDInCgroup(t *testing.T) {
+	self := strings.Repeat("c", 64)
+	tests := []struct {
+		name   string
+		cgroup []string
+		want   string
+	}{
+		{"cgroup v1", []string{"12:memory:/docker/" + self, "1:name=systemd:/docker/" + self}, self},
+		{"systemd driver", []string{"0::/system.slice/docker-" + self + ".scope"}, self},
+		{"cgroup v2 namespace", []string{"0::/"}, ""},
+		{"other runtime", []string{"0::/kubepods/pod1/" + self}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cgroup")
+			if err := os.WriteFile(path, []byte(strings.Join(tt.cgroup, "\n")), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if got := findContainerID(path, isDockerCgroup); got != tt.want {
+				t.Errorf("findContainerID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSelf(t *testing.T) {
+	self := strings.Repeat("d", 64)
+	tests := []struct {
+		selfID      string
+		containerID string
+		want        bool
+	}{
+		{self, self, true},
+		{self, self[:12], true},
+		{self, "eeeeeeeeeeee", false},
+		{self, "", false},
+		{"", self, false},
+	}
+	for _, tt := range tests {
+		if got := isSelf(tt.selfID, tt.containerID); got != tt.want {
+			t.Errorf("isSelf(%q, %q) = %v, want %v", tt.selfID, tt.containerID, got, tt.want)
+		}
+	}
+}
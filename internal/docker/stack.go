@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/mrmarble/teledock/internal/constants"
+)
+
+// StackLevels groups the containers of a stack by how deep they are in the
+// com.docker.compose.depends_on graph, so each level only depends on the previous
+// ones. Services depending on each other in a cycle all go in the last level.
+func StackLevels(containers []types.Container) [][]types.Container {
+	byService := map[string][]types.Container{}
+	for _, container := range containers {
+		service := container.Labels[constants.ComposeServiceLabel]
+		byService[service] = append(byService[service], container)
+	}
+
+	placed := map[string]bool{}
+	levels := [][]types.Container{}
+	for len(placed) < len(byService) {
+		ready := []string{}
+		for service := range byService {
+			if placed[service] {
+				continue
+			}
+			blocked := false
+			for _, dependency := range dependsOn(byService[service][0]) {
+				if _, inStack := byService[dependency]; inStack && !placed[dependency] {
+					blocked = true
+				}
+			}
+			if !blocked {
+				ready = append(ready, service)
+			}
+		}
+		if len(ready) == 0 {
+			for service := range byService {
+				if !placed[service] {
+					ready = append(ready, service)
+				}
+			}
+		}
+
+		sort.Strings(ready)
+		level := []types.Container{}
+		for _, service := range ready {
+			placed[service] = true
+			level = append(level, byService[service]...)
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// dependsOn returns the services a container depends on. The label holds a comma
+// separated list of "service:condition:restart", or just "service" in older compose versions.
+func dependsOn(container types.Container) []string {
+	services := []string{}
+	for _, dependency := range strings.Split(container.Labels[constants.ComposeDependsOnLabel], ",") {
+		if service := strings.TrimSpace(strings.SplitN(dependency, ":", 2)[0]); service != "" {
+			services = append(services, service)
+		}
+	}
+	return services
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/mrmarble/teledock/internal/constants"
+)
+
+func service(name, dependsOn string) types.Container {
+	return types.Container{ID: name, Labels: map[string]string{
+		constants.ComposeServiceLabel:   name,
+		constants.ComposeDependsOnLabel: dependsOn,
+	}}
+}
+
+func TestStackLevels(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []types.Container
+		want       [][]string
+	}{
+		{
+			name:       "no dependencies",
+			containers: []types.Container{service("web", ""), service("db", "")},
+			want:       [][]string{{"db", "web"}},
+		},
+		{
+			name: "chain",
+			containers: []types.Container{
+				service("web", "api:service_started:false"),
+				service("api", "db:service_healthy:false,cache:service_started:false"),
+				service("db", ""),
+				service("cache", ""),
+			},
+			want: [][]string{{"cache", "db"}, {"api"}, {"web"}},
+		},
+		{
+			name:       "old compose labels",
+			containers: []types.Container{service("web", "db"), service("db", "")},
+			want:       [][]string{{"db"}, {"web"}},
+		},
+		{
+			name:       "missing dependency",
+			containers: []types.Container{service("web", "gone:service_started:false"), service("db", "")},
+			want:       [][]string{{"db", "web"}},
+		},
+		{
+			name: "cycle",
+			containers: []types.Container{
+				service("a", "b:service_started:false"),
+				service("b", "a:service_started:false"),
+				service("db", ""),
+				service("web", "a:service_started:false"),
+			},
+			want: [][]string{{"db"}, {"a", "b", "web"}},
+		},
+		{
+			name:       "replicas",
+			containers: []types.Container{service("web", "db"), service("web", "db"), service("db", "")},
+			want:       [][]string{{"db"}, {"web", "web"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := [][]string{}
+			for _, level := range StackLevels(tt.containers) {
+				services := []string{}
+				for _, container := range level {
+					services = append(services, container.Labels[constants.ComposeServiceLabel])
+				}
+				got = append(got, services)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StackLevels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Stats is a resource usage sample of a container.
+type Stats struct {
+	ContainerID string
+	Name        string
+	// CPUPercent is relative to one CPU, a container using two full CPUs is at 200%.
+	CPUPercent    float64
+	MemoryUsage   uint64
+	MemoryLimit   uint64
+	MemoryPercent float64
+	NetRx         uint64
+	NetTx         uint64
+	BlockRead     uint64
+	BlockWrite    uint64
+	Time          time.Time
+	// Labels are the labels of the container, only set on the samples of a Sampler.
+	Labels map[string]string
+}
+
+// newStats computes the usage of a container out of a stats sample, the same way the docker CLI does.
+func newStats(sample types.StatsJSON) *Stats {
+	stats := &Stats{ContainerID: sample.ID, Name: strings.TrimPrefix(sample.Name, "/"), Time: sample.Read}
+
+	cpuDelta := float64(sample.CPUStats.CPUUsage.TotalUsage) - float64(sample.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(sample.CPUStats.SystemUsage) - float64(sample.PreCPUStats.SystemUsage)
+	cpus := float64(sample.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(sample.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	// Page cache is not counted, cgroup v1 reports it as total_inactive_file and v2 as inactive_file.
+	stats.MemoryUsage = sample.MemoryStats.Usage
+	if cache, ok := sample.MemoryStats.Stats["total_inactive_file"]; ok && cache < stats.MemoryUsage {
+		stats.MemoryUsage -= cache
+	} else if cache, ok := sample.MemoryStats.Stats["inactive_file"]; ok && cache < stats.MemoryUsage {
+		stats.MemoryUsage -= cache
+	}
+	stats.MemoryLimit = sample.MemoryStats.Limit
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+
+	for _, network := range sample.Networks {
+		stats.NetRx += network.RxBytes
+		stats.NetTx += network.TxBytes
+	}
+	for _, entry := range sample.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+	return stats
+}
+
+// Stats returns the current resource usage of a container. It takes about a
+// second, the daemon needs two samples to compute the CPU usage.
+func (d *Docker) Stats(containerID string) (*Stats, error) {
+	if err := d.reconnect(); err != nil {
+		return nil, err
+	}
+	response, err := d.cli.ContainerStats(d.ctx, containerID, false)
+	if err != nil {
+		return nil, d.wrap("stats", containerID, err)
+	}
+	defer response.Body.Close()
+
+	var sample types.StatsJSON
+	if err := json.NewDecoder(response.Body).Decode(&sample); err != nil {
+		return nil, d.wrap("stats", containerID, err)
+	}
+	return newStats(sample), nil
+}
@@ -0,0 +1,316 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/constants"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// alertResolveRatio is the share of its threshold a value has to stay under for a
+// firing alert to resolve, so values hovering around the threshold don't flap.
+const alertResolveRatio = 0.9
+
+// alertState is the state of a rule for one container.
+type alertState struct {
+	name   string
+	labels map[string]string
+	firing bool
+	// since is when the value crossed to the other side of the threshold, zero if it didn't.
+	since time.Time
+}
+
+// alertRegistry tracks the state of every rule and container, and the muted rules.
+type alertRegistry struct {
+	mu sync.Mutex
+	// states holds the state of each container, keyed by rule name and container ID.
+	states map[string]map[string]*alertState
+	// muted holds when each muted rule is unmuted, zero for never.
+	muted map[string]time.Time
+}
+
+func newAlertRegistry() *alertRegistry {
+	return &alertRegistry{states: map[string]map[string]*alertState{}, muted: map[string]time.Time{}}
+}
+
+// observe records the value of the rule for the container of sample and reports
+// whether the alert just fired or resolved, and which of both.
+func (r *alertRegistry) observe(rule config.AlertRule, sample docker.Stats, value float64) (bool, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.states[rule.Name] == nil {
+		r.states[rule.Name] = map[string]*alertState{}
+	}
+	state, ok := r.states[rule.Name][sample.ContainerID]
+	if !ok {
+		state = &alertState{}
+		r.states[rule.Name][sample.ContainerID] = state
+	}
+	state.name, state.labels = sample.Name, sample.Labels
+
+	crossed := value > rule.Threshold
+	if state.firing {
+		crossed = value < rule.Threshold*alertResolveRatio
+	}
+	if !crossed {
+		state.since = time.Time{}
+		return false, state.firing
+	}
+	if state.since.IsZero() {
+		state.since = sample.Time
+	}
+	if sample.Time.Sub(state.since) < rule.For {
+		return false, state.firing
+	}
+	state.firing, state.since = !state.firing, time.Time{}
+	return true, state.firing
+}
+
+// forget drops the states of the containers that are not running anymore. A
+// container whose sample failed keeps its state, so it doesn't fire again.
+func (r *alertRegistry) forget(running []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alive := map[string]bool{}
+	for _, containerID := range running {
+		alive[containerID] = true
+	}
+	for _, states := range r.states {
+		for containerID := range states {
+			if !alive[containerID] {
+				delete(states, containerID)
+			}
+		}
+	}
+}
+
+// firing returns the names of the containers the rule is firing for, within reach of access.
+func (r *alertRegistry) firing(rule string, access config.Access) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := []string{}
+	for _, state := range r.states[rule] {
+		if state.firing && access.Allows(state.name, state.labels) {
+			names = append(names, state.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mute silences rule until the given time, zero for good.
+func (r *alertRegistry) mute(rule string, until time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.muted[rule] = until
+}
+
+func (r *alertRegistry) unmute(rule string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.muted, rule)
+}
+
+// mutedUntil reports whether rule is muted and until when, zero for good.
+func (r *alertRegistry) mutedUntil(rule string) (bool, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until, ok := r.muted[rule]
+	if ok && !until.IsZero() && time.Now().After(until) {
+		delete(r.muted, rule)
+		return false, time.Time{}
+	}
+	return ok, until
+}
+
+// checkAlerts evaluates the alert rules against a round of samples of the sampler.
+func (t *Telegram) checkAlerts(samples []docker.Stats, running []string) {
+	t.alerts.forget(running)
+	for _, rule := range t.cfg.Alerts {
+		for _, sample := range samples {
+			if !rule.Matches(sample.Name, sample.Labels) {
+				continue
+			}
+			value := alertValue(rule.Metric, sample)
+			changed, firing := t.alerts.observe(rule, sample, value)
+			if !changed {
+				continue
+			}
+			if muted, _ := t.alerts.mutedUntil(rule.Name); muted {
+				continue
+			}
+			t.sendAlert(rule, sample, value, firing)
+		}
+	}
+}
+
+// sendAlert tells the chats of rule it fired or resolved for the container of sample.
+func (t *Telegram) sendAlert(rule config.AlertRule, sample docker.Stats, value float64, firing bool) {
+	text := fmt.Sprintf("%v <code>%v</code>: %v back to %.1f%%", emoji.CheckMarkButton, html.EscapeString(sample.Name), rule.Metric, value)
+	options := []interface{}{}
+	if firing {
+		text = fmt.Sprintf("%v <code>%v</code>: %v at %.1f%%", emoji.Bell, html.EscapeString(sample.Name), rule.Metric, value)
+		options = append(options, t.makeEventMenu(docker.Event{ContainerID: sample.ContainerID}))
+	}
+	text = fmt.Sprintf("%v\nRule %v: <code>%v</code>", text, html.EscapeString(rule.Name), html.EscapeString(rule.Rule))
+	for _, chatID := range t.cfg.AlertChats(rule) {
+		t.outbox.push(chatID, text, options...)
+	}
+}
+
+// alertValue returns the usage metric of an alert rule watches, in percent.
+func alertValue(metric string, sample docker.Stats) float64 {
+	if metric == "mem" {
+		return sample.MemoryPercent
+	}
+	return sample.CPUPercent
+}
+
+// handleAlerts lists the alert rules, or mutes and unmutes one. [mute|unmute] [rule] [duration]
+func (t *Telegram) handleAlerts(m *tb.Message) {
+	if len(t.cfg.Alerts) == 0 {
+		t.reply(m, "No alert rules configured")
+		return
+	}
+	args := strings.Fields(m.Payload)
+	if len(args) == 0 {
+		text, menu := t.formatAlerts(t.access(m.Chat, m.Sender), m.Sender.ID)
+		t.reply(m, text, menu)
+		return
+	}
+
+	usage := "Usage: /alerts <code>[mute|unmute] [rule] [duration]</code>"
+	if len(args) < 2 || len(args) > 3 || (args[0] != "mute" && args[0] != "unmute") || (args[0] == "unmute" && len(args) > 2) {
+		t.reply(m, usage)
+		return
+	}
+	rule, ok := t.findAlert(args[1])
+	if !ok {
+		t.reply(m, fmt.Sprintf("Unknown alert rule <code>%v</code>", html.EscapeString(args[1])))
+		return
+	}
+	if !t.access(m.Chat, m.Sender).Covers(rule.Names, rule.Labels) {
+		t.reply(m, outOfReachAlert(rule.Name))
+		return
+	}
+	if args[0] == "unmute" {
+		t.alerts.unmute(args[1])
+		t.reply(m, fmt.Sprintf("Alert rule %v unmuted", html.EscapeString(args[1])))
+		return
+	}
+	duration := time.Duration(0)
+	if len(args) == 3 {
+		var err error
+		if duration, err = time.ParseDuration(args[2]); err != nil || duration <= 0 {
+			t.reply(m, usage)
+			return
+		}
+	}
+	t.reply(m, t.muteAlert(args[1], duration))
+}
+
+// handleAlertCallback mutes or unmutes a rule from the /alerts menu and refreshes it.
+func (t *Telegram) handleAlertCallback(c *tb.Callback, instruction string, rule string, args []string) {
+	found, ok := t.findAlert(rule)
+	if !ok {
+		t.callbackResponse(c, nil, rule, fmt.Sprintf("Unknown alert rule <code>%v</code>", html.EscapeString(rule)))
+		return
+	}
+	access := t.access(c.Message.Chat, c.Sender)
+	if !access.Covers(found.Names, found.Labels) {
+		t.alert(c, outOfReachAlert(rule))
+		return
+	}
+	if instruction == "unmute" {
+		t.alerts.unmute(rule)
+	} else {
+		duration := time.Duration(0)
+		if len(args) > 0 {
+			duration, _ = time.ParseDuration(args[0])
+		}
+		t.muteAlert(rule, duration)
+	}
+	text, menu := t.formatAlerts(access, c.Sender.ID)
+	t.callbackResponse(c, nil, rule, text, menu)
+}
+
+// muteAlert silences rule for duration, or for good if zero, and describes it.
+func (t *Telegram) muteAlert(rule string, duration time.Duration) string {
+	if duration == 0 {
+		t.alerts.mute(rule, time.Time{})
+		return fmt.Sprintf("Alert rule %v muted", html.EscapeString(rule))
+	}
+	until := time.Now().Add(duration)
+	t.alerts.mute(rule, until)
+	return fmt.Sprintf("Alert rule %v muted until %v", html.EscapeString(rule), until.Format("2006-01-02 15:04"))
+}
+
+// outOfReachAlert tells a scoped user the rule also watches containers of others,
+// muting it is left to someone who reaches all of them.
+func outOfReachAlert(rule string) string {
+	return fmt.Sprintf("%v Alert rule %v watches containers out of your reach", emoji.NoEntry, html.EscapeString(rule))
+}
+
+func (t *Telegram) findAlert(name string) (config.AlertRule, bool) {
+	for _, rule := range t.cfg.Alerts {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return config.AlertRule{}, false
+}
+
+// formatAlerts describes the alert rules and builds the menu to mute them.
+func (t *Telegram) formatAlerts(access config.Access, owner int64) (string, *tb.ReplyMarkup) {
+	blocks := []string{}
+	rows := [][]tb.InlineButton{}
+	for _, rule := range t.cfg.Alerts {
+		icon := emoji.Bell
+		lines := []string{}
+		containers := append(append([]string{}, rule.Names...), rule.Labels...)
+		if len(containers) == 0 {
+			containers = []string{"every container"}
+		}
+		lines = append(lines, fmt.Sprintf(constants.FormatedStrPadded, "Watches:", html.EscapeString(strings.Join(containers, ", "))))
+		if firing := t.alerts.firing(rule.Name, access); len(firing) > 0 {
+			lines = append(lines, fmt.Sprintf(constants.FormatedStrPadded, "Firing:", html.EscapeString(strings.Join(firing, ", "))))
+		}
+
+		muted, until := t.alerts.mutedUntil(rule.Name)
+		switch {
+		case muted && until.IsZero():
+			icon = emoji.BellWithSlash
+			lines = append(lines, fmt.Sprintf(constants.FormatedStrPadded, "Muted:", "yes"))
+		case muted:
+			icon = emoji.BellWithSlash
+			lines = append(lines, fmt.Sprintf(constants.FormatedStrPadded, "Muted:", until.Format("2006-01-02 15:04")))
+		}
+		switch {
+		case !access.Covers(rule.Names, rule.Labels):
+			// Only the rules watching containers all within reach can be muted.
+		case muted:
+			rows = append(rows, []tb.InlineButton{{Text: fmt.Sprintf("Unmute %v", rule.Name), Data: fmt.Sprintf("unmute:%v", rule.Name)}})
+		default:
+			rows = append(rows, []tb.InlineButton{
+				{Text: fmt.Sprintf("Mute %v 1h", rule.Name), Data: fmt.Sprintf("mute:%v:1h", rule.Name)},
+				{Text: fmt.Sprintf("Mute %v", rule.Name), Data: fmt.Sprintf("mute:%v", rule.Name)},
+			})
+		}
+
+		header := fmt.Sprintf("%v <b>%v</b> <code>%v</code>", icon, html.EscapeString(rule.Name), html.EscapeString(rule.Rule))
+		blocks = append(blocks, strings.Join(append([]string{header}, lines...), "\n"))
+	}
+	return strings.Join(blocks, "\n\n"), t.newMenu(owner, rows)
+}
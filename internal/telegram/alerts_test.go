@@ -0,0 +1,154 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestHandleAlertsMuteScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  int64
+		rule  string
+		muted bool
+	}{
+		{"unscoped mutes a global rule", 1, "all", true},
+		{"scoped can't mute a global rule", 2, "all", false},
+		{"scoped mutes a rule of its scope", 2, "shop", true},
+		{"scoped can't mute a rule of others", 2, "blog", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := scopedConfig()
+			cfg.Alerts = []config.AlertRule{
+				{Name: "all", Rule: "cpu > 90%"},
+				{Name: "shop", Rule: "cpu > 90%", Names: []string{"shop-*"}},
+				{Name: "blog", Rule: "cpu > 90%", Names: []string{"blog-*"}},
+			}
+			bot := newFakeBot()
+			telegram, err := New(cfg, bot, docker.NewFake(), compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			telegram.handleAlerts(&tb.Message{Chat: &tb.Chat{ID: tt.user}, Sender: &tb.User{ID: tt.user}, Payload: "mute " + tt.rule})
+			if muted, _ := telegram.alerts.mutedUntil(tt.rule); muted != tt.muted {
+				t.Errorf("rule %v muted %v, want %v (reply %q)", tt.rule, muted, tt.muted, bot.last().Text)
+			}
+
+			text, menu := telegram.formatAlerts(cfg.Access(tt.user, tt.user), tt.user)
+			button := false
+			for _, row := range menu.InlineKeyboard {
+				for _, b := range row {
+					button = button || strings.HasSuffix(b.Text, " "+tt.rule)
+				}
+			}
+			if button != tt.muted {
+				t.Errorf("menu has a button for %v %v, want %v\n%v", tt.rule, button, tt.muted, text)
+			}
+		})
+	}
+}
+
+func TestAlertRegistryObserve(t *testing.T) {
+	start := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	type step struct {
+		minutes float64
+		value   float64
+		changed bool
+		firing  bool
+	}
+	tests := []struct {
+		name  string
+		For   time.Duration
+		steps []step
+	}{
+		{
+			name: "fires right away without a duration",
+			steps: []step{
+				{0, 50, false, false},
+				{1, 95, true, true},
+				{2, 95, false, true},
+			},
+		},
+		{
+			name: "fires after staying over for the duration",
+			For:  5 * time.Minute,
+			steps: []step{
+				{0, 95, false, false},
+				{3, 95, false, false},
+				{5, 95, true, true},
+			},
+		},
+		{
+			name: "dropping under resets the duration",
+			For:  5 * time.Minute,
+			steps: []step{
+				{0, 95, false, false},
+				{3, 50, false, false},
+				{5, 95, false, false},
+				{9, 95, false, false},
+				{10, 95, true, true},
+			},
+		},
+		{
+			name: "resolves only under the hysteresis",
+			steps: []step{
+				{0, 95, true, true},
+				{1, 85, false, true},
+				{2, 80, true, false},
+				{3, 85, false, false},
+			},
+		},
+		{
+			name: "resolves after staying under for the duration",
+			For:  5 * time.Minute,
+			steps: []step{
+				{0, 95, false, false},
+				{5, 95, true, true},
+				{6, 10, false, true},
+				{11, 10, true, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newAlertRegistry()
+			rule := config.AlertRule{Name: "hot", Metric: "cpu", Threshold: 90, For: tt.For}
+			for index, step := range tt.steps {
+				sample := docker.Stats{ContainerID: "abc", Name: "web", Time: start.Add(time.Duration(step.minutes * float64(time.Minute)))}
+				changed, firing := registry.observe(rule, sample, step.value)
+				if changed != step.changed || firing != step.firing {
+					t.Fatalf("step %v (%v at %vm) = %v, %v, want %v, %v", index, step.value, step.minutes, changed, firing, step.changed, step.firing)
+				}
+			}
+		})
+	}
+}
+
+func TestAlertRegistryForget(t *testing.T) {
+	now := time.Now()
+	registry := newAlertRegistry()
+	rule := config.AlertRule{Name: "hot", Metric: "cpu", Threshold: 90}
+	for _, name := range []string{"web", "db"} {
+		registry.observe(rule, docker.Stats{ContainerID: name, Name: name, Time: now}, 95)
+	}
+
+	// db is still running but its sample failed, only web is gone.
+	registry.forget([]string{"db"})
+	if firing := registry.firing("hot", config.Access{Role: config.RoleAdmin}); len(firing) != 1 || firing[0] != "db" {
+		t.Fatalf("firing = %v, want [db]", firing)
+	}
+	if changed, _ := registry.observe(rule, docker.Stats{ContainerID: "db", Name: "db", Time: now}, 95); changed {
+		t.Error("db fired again after forget")
+	}
+	if changed, _ := registry.observe(rule, docker.Stats{ContainerID: "web", Name: "web", Time: now}, 95); !changed {
+		t.Error("web did not fire again after it was forgotten")
+	}
+}
@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/audit"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// defaultAuditEntries is how many entries /audit shows when not told.
+	defaultAuditEntries = 20
+	// maxAuditEntries caps the entries /audit shows.
+	maxAuditEntries = 1000
+	// maxAuditArgs caps the arguments recorded for an action.
+	maxAuditArgs = 200
+)
+
+// auditTracker keeps the entries of the commands and callbacks being handled so
+// their handlers can record what happened.
+type auditTracker struct {
+	mu      sync.Mutex
+	entries map[string]*audit.Entry
+}
+
+func newAuditTracker() *auditTracker {
+	return &auditTracker{entries: map[string]*audit.Entry{}}
+}
+
+func (a *auditTracker) begin(key string, entry *audit.Entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[key] = entry
+}
+
+// update changes the entry of key, if it is being tracked.
+func (a *auditTracker) update(key string, change func(*audit.Entry)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if entry, ok := a.entries[key]; ok {
+		change(entry)
+	}
+}
+
+func (a *auditTracker) end(key string) *audit.Entry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry := a.entries[key]
+	delete(a.entries, key)
+	return entry
+}
+
+func messageKey(m *tb.Message) string {
+	return fmt.Sprintf("m%v:%v", m.Chat.ID, m.ID)
+}
+
+func callbackKey(c *tb.Callback) string {
+	return "c" + c.ID
+}
+
+// newAuditEntry starts the entry of an action of user in chat.
+func newAuditEntry(chat *tb.Chat, user *tb.User, kind string, command string) *audit.Entry {
+	entry := &audit.Entry{Time: time.Now(), ChatID: chat.ID, Kind: kind, Command: command, Result: audit.ResultOK}
+	if user != nil {
+		entry.UserID, entry.Username = user.ID, user.Username
+	}
+	return entry
+}
+
+// auditTarget splits a command payload into the container it targets, if any, and the rest.
+func (t *Telegram) auditTarget(payload string) (string, string) {
+	target := ""
+	for _, field := range strings.Fields(payload) {
+		if t.dckr.IsValidID(field) {
+			target = field
+			break
+		}
+	}
+	return target, truncate(strings.TrimSpace(payload), maxAuditArgs)
+}
+
+// auditError records err as the result of the action of key.
+func (t *Telegram) auditError(key string, err error) {
+	t.audits.update(key, func(entry *audit.Entry) {
+		entry.Result = cause(err)
+	})
+}
+
+// endAudit records the action of key.
+func (t *Telegram) endAudit(key string) {
+	if entry := t.audits.end(key); entry != nil {
+		t.recordAudit(entry)
+	}
+}
+
+// recordAudit writes a finished entry to the audit log and the audit chat.
+func (t *Telegram) recordAudit(entry *audit.Entry) {
+	entry.Duration = time.Since(entry.Time).Milliseconds()
+	log.Info().Int64("user", entry.UserID).Str("command", entry.Command).Str("target", entry.Target).Str("result", entry.Result).Msg("audit")
+
+	if t.audit != nil {
+		if err := t.audit.Append(*entry); err != nil {
+			log.Error().Err(err).Msg("error writing audit log")
+		}
+	}
+	if t.cfg.AuditChat != 0 {
+		t.outbox.push(t.cfg.AuditChat, fmt.Sprintf(FormatedStr, html.EscapeString(entry.String())))
+	}
+}
+
+// replyError replies with err and records it as the result of the command.
+func (t *Telegram) replyError(m *tb.Message, err error) {
+	t.auditError(messageKey(m), err)
+	t.reply(m, formatError(err))
+}
+
+// handleAudit shows the last audit log entries. [n] [@username|user:id]
+func (t *Telegram) handleAudit(m *tb.Message) {
+	if t.audit == nil {
+		t.reply(m, "The audit log is disabled, <code>TELEDOCK_AUDIT_FILE</code> is empty")
+		return
+	}
+
+	count, user, ok := parseAuditArgs(m.Payload)
+	if !ok {
+		t.reply(m, "Usage: /audit <code>[n] [@username|user:id]</code>")
+		return
+	}
+
+	entries, err := t.audit.Recent(count, user)
+	if err != nil {
+		t.replyError(m, err)
+		return
+	}
+	if len(entries) == 0 {
+		t.reply(m, "No actions recorded")
+		return
+	}
+	lines := make([]string, len(entries))
+	for index, entry := range entries {
+		lines[index] = entry.String()
+	}
+	t.replyOutput(m, "audit.log", fmt.Sprintf("Last %v actions", len(entries)), lines)
+}
+
+// parseAuditArgs reads the number of entries and the user of the /audit arguments.
+// A bare number is always the count, user IDs are given as user:<id> so they
+// can't be mistaken for one.
+func parseAuditArgs(payload string) (int, string, bool) {
+	count, user := defaultAuditEntries, ""
+	fields := strings.Fields(payload)
+	if len(fields) > 0 {
+		if number, err := strconv.Atoi(fields[0]); err == nil {
+			if number < 1 {
+				return 0, "", false
+			}
+			count, fields = number, fields[1:]
+		}
+	}
+	if count > maxAuditEntries {
+		count = maxAuditEntries
+	}
+	if len(fields) > 1 {
+		return 0, "", false
+	}
+	if len(fields) == 1 {
+		switch user = fields[0]; {
+		case strings.HasPrefix(user, "@"):
+		case strings.HasPrefix(user, "user:"):
+			user = strings.TrimPrefix(user, "user:")
+			if _, err := strconv.ParseInt(user, 10, 64); err != nil {
+				return 0, "", false
+			}
+		default:
+			return 0, "", false
+		}
+	}
+	return count, user, true
+}
+
+// truncate cuts s to size runes.
+func truncate(s string, size int) string {
+	if runes := []rune(s); len(runes) > size {
+		return string(runes[:size]) + "…"
+	}
+	return s
+}
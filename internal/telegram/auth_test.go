@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestAuthorize(t *testing.T) {
+	const (
+		operator = 1
+		viewer   = 2
+		stranger = 3
+	)
+	tests := []struct {
+		name     string
+		user     int64
+		required config.Role
+		override map[string]config.Role
+		ran      bool
+		reply    string
+	}{
+		{"allowed", viewer, config.RoleViewer, nil, true, ""},
+		{"higher role", operator, config.RoleViewer, nil, true, ""},
+		{"role too low", viewer, config.RoleOperator, nil, false, "requires the operator role, you are viewer"},
+		{"lowered by the config", viewer, config.RoleOperator, map[string]config.Role{"cmd": config.RoleViewer}, true, ""},
+		{"raised by the config", operator, config.RoleViewer, map[string]config.Role{"cmd": config.RoleAdmin}, false, "requires the admin role"},
+		{"unknown user is ignored", stranger, config.RoleViewer, nil, false, ""},
+		{"public command", stranger, config.RoleNone, nil, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Users:    []config.User{{ID: operator, Role: config.RoleOperator}, {ID: viewer, Role: config.RoleViewer}},
+				Commands: tt.override,
+			}
+			bot := newFakeBot()
+			telegram, err := New(cfg, bot, docker.NewFake(), compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ran := false
+			telegram.roles["cmd"] = tt.required
+			handler := telegram.authorize(Command{Cmd: "cmd", Role: tt.required, Handler: func(*tb.Message) { ran = true }})
+			handler(&tb.Message{Chat: &tb.Chat{ID: tt.user}, Sender: &tb.User{ID: tt.user}})
+			if ran != tt.ran {
+				t.Errorf("handler ran %v, want %v", ran, tt.ran)
+			}
+			if reply := bot.last().Text; !strings.Contains(reply, tt.reply) || (tt.reply == "" && reply != "") {
+				t.Errorf("reply = %q, want %q", reply, tt.reply)
+			}
+		})
+	}
+}
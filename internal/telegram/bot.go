@@ -0,0 +1,16 @@
+package telegram
+
+import tb "gopkg.in/tucnak/telebot.v2"
+
+// Bot is the part of the telegram API the handlers use.
+type Bot interface {
+	Send(to tb.Recipient, what interface{}, options ...interface{}) (*tb.Message, error)
+	Reply(to *tb.Message, what interface{}, options ...interface{}) (*tb.Message, error)
+	Edit(message tb.Editable, what interface{}, options ...interface{}) (*tb.Message, error)
+	Respond(c *tb.Callback, responses ...*tb.CallbackResponse) error
+	Handle(endpoint interface{}, handler interface{})
+	SetCommands(commands []tb.Command) error
+	Start()
+}
+
+var _ Bot = (*tb.Bot)(nil)
@@ -0,0 +1,224 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// maxCallbackData is the longest callback data telegram accepts, in bytes.
+	maxCallbackData = 64
+	// longCallbackPrefix marks callback data standing for data kept by callbackStore.
+	longCallbackPrefix = "~"
+)
+
+var (
+	errCallbackInvalid = errors.New("this button is not valid anymore, run the command again")
+	errCallbackExpired = errors.New("this button expired, run the command again")
+	errCallbackOwner   = errors.New("only the user who asked can use these buttons")
+	errCallbackRole    = errors.New("you are not allowed to do that here")
+	errCallbackTooLong = errors.New("callback data too long")
+)
+
+// callbackCommands maps callback actions to the command whose role they require.
+// Actions not listed, like confirmations, are checked by their own handlers.
+var callbackCommands = map[string]string{
+	"stop":    "stop",
+	"start":   "run",
+	"restart": "restart",
+	"pause":   "pause",
+	"unpause": "unpause",
+	"kill":    "kill",
+	// Disabling the restart policy of a crash looping container is as bad as stopping it.
+	"norestart":     "stop",
+	"inspect":       "inspect",
+	"logs":          "logs",
+	"tail":          "tail",
+	"untail":        "untail",
+	"create":        "create",
+	"stackstart":    "run",
+	"stackstop":     "stop",
+	"stackrestart":  "restart",
+	"composeup":     "compose",
+	"composedown":   "compose",
+	"composepull":   "compose",
+	"composeconfig": "compose",
+	"navl":          "stacks",
+	"navs":          "stacks",
+	"navc":          "stacks",
+	"navg":          "logs",
+	"navi":          "inspect",
+	"navr":          "restart",
+	"mute":          "alerts",
+	"unmute":        "alerts",
+}
+
+// newCallbackKey returns the key callback data is signed with.
+func newCallbackKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal().Err(err).Msg("error generating callback key")
+	}
+	return key
+}
+
+// storedCallback is the data of a button too long to fit in its callback.
+type storedCallback struct {
+	data    string
+	expires time.Time
+}
+
+// callbackStore keeps the data of the buttons too long for telegram by token, the
+// buttons only carry the token. Tokens are lost on restart like confirmations.
+type callbackStore struct {
+	mu   sync.Mutex
+	data map[string]*storedCallback
+}
+
+func newCallbackStore() *callbackStore {
+	return &callbackStore{data: map[string]*storedCallback{}}
+}
+
+// add stores data until expires and returns its token.
+func (s *callbackStore) add(data string, expires time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, stored := range s.data {
+		if time.Now().After(stored.expires) {
+			delete(s.data, token)
+		}
+	}
+
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		log.Error().Err(err).Msg("error generating callback token")
+	}
+	token := hex.EncodeToString(bytes)
+	s.data[token] = &storedCallback{data: data, expires: expires}
+	return token
+}
+
+// get returns the data stored with token, buttons can be pressed more than once.
+func (s *callbackStore) get(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.data[token]
+	if !ok || time.Now().After(stored.expires) {
+		return "", false
+	}
+	return stored.data, true
+}
+
+// newMenu builds an inline keyboard whose buttons only work for owner until they expire.
+// Buttons with owner 0 work for anyone allowed to run their action in the chat.
+// Buttons whose data can't be signed are dropped, telegram would reject the whole
+// keyboard otherwise.
+func (t *Telegram) newMenu(owner int64, rows [][]tb.InlineButton) *tb.ReplyMarkup {
+	expires := time.Now().Add(t.cfg.CallbackTimeout).Unix()
+	keyboard := make([][]tb.InlineButton, 0, len(rows))
+	for _, row := range rows {
+		buttons := make([]tb.InlineButton, 0, len(row))
+		for _, button := range row {
+			data, err := t.signCallback(button.Data, owner, expires)
+			if err != nil {
+				log.Error().Err(err).Str("data", button.Data).Msg("dropping button")
+				continue
+			}
+			button.Data = data
+			buttons = append(buttons, button)
+		}
+		if len(buttons) > 0 {
+			keyboard = append(keyboard, buttons)
+		}
+	}
+	menu := &tb.ReplyMarkup{}
+	menu.InlineKeyboard = keyboard
+	return menu
+}
+
+// signCallback appends the owner, expiry and signature to callback data as
+// "data|owner|expires|signature". Data too long for telegram is kept in the
+// callbackStore and replaced by its token.
+func (t *Telegram) signCallback(data string, owner int64, expires int64) (string, error) {
+	sign := func(data string) string {
+		signed := strings.Join([]string{data, strconv.FormatInt(owner, 36), strconv.FormatInt(expires, 36)}, "|")
+		return signed + "|" + t.callbackSignature(signed)
+	}
+	signed := sign(data)
+	if len(signed) > maxCallbackData || strings.HasPrefix(data, longCallbackPrefix) {
+		signed = sign(longCallbackPrefix + t.longCallbacks.add(data, time.Unix(expires, 0)))
+	}
+	if len(signed) > maxCallbackData {
+		return "", errCallbackTooLong
+	}
+	return signed, nil
+}
+
+func (t *Telegram) callbackSignature(data string) string {
+	mac := hmac.New(sha256.New, t.callbackKey)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// verifyCallback checks the signature, expiry and owner of the callback data and
+// that the sender can still run its action, and returns the data without them.
+func (t *Telegram) verifyCallback(c *tb.Callback) (string, error) {
+	parts := strings.Split(c.Data, "|")
+	if len(parts) != 4 {
+		return "", errCallbackInvalid
+	}
+	signed := strings.Join(parts[:3], "|")
+	if !hmac.Equal([]byte(parts[3]), []byte(t.callbackSignature(signed))) {
+		log.Warn().Int64("user", c.Sender.ID).Str("data", c.Data).Msg("callback with invalid signature")
+		return "", errCallbackInvalid
+	}
+	owner, ownerErr := strconv.ParseInt(parts[1], 36, 64)
+	expires, expiresErr := strconv.ParseInt(parts[2], 36, 64)
+	if ownerErr != nil || expiresErr != nil {
+		return "", errCallbackInvalid
+	}
+	if time.Now().Unix() > expires {
+		return "", errCallbackExpired
+	}
+	if owner != 0 && owner != c.Sender.ID {
+		return "", errCallbackOwner
+	}
+	data := parts[0]
+	if strings.HasPrefix(data, longCallbackPrefix) {
+		var ok bool
+		if data, ok = t.longCallbacks.get(strings.TrimPrefix(data, longCallbackPrefix)); !ok {
+			return "", errCallbackExpired
+		}
+	}
+
+	action := strings.SplitN(data, ":", 2)[0]
+	if command, ok := callbackCommands[action]; ok {
+		if t.access(c.Message.Chat, c.Sender).Role < t.requiredRole(command) {
+			log.Warn().Int64("user", c.Sender.ID).Str("action", action).Msg("unauthorized callback")
+			return "", errCallbackRole
+		}
+	}
+	return data, nil
+}
+
+// alert answers a callback with a popup.
+func (t *Telegram) alert(c *tb.Callback, text string) {
+	if err := t.bot.Respond(c, &tb.CallbackResponse{Text: text, ShowAlert: true}); err != nil {
+		log.Error().Err(err).Msg("error replying to callback")
+	}
+}
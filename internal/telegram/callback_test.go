@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// newCallbackBot returns a bot signing callbacks with secret, where user 1 is an
+// operator and user 2 a viewer.
+func newCallbackBot(t *testing.T, secret string) *Telegram {
+	t.Helper()
+	cfg := &config.Config{
+		Users:          []config.User{{ID: 1, Role: config.RoleOperator}, {ID: 2, Role: config.RoleViewer}},
+		CallbackSecret: secret,
+	}
+	telegram, err := New(cfg, newFakeBot(), docker.NewFake(), compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram.roles["stop"] = config.RoleOperator
+	return telegram
+}
+
+func TestVerifyCallback(t *testing.T) {
+	const operator, viewer = 1, 2
+	telegram := newCallbackBot(t, "secret")
+	future, past := time.Now().Add(time.Hour).Unix(), time.Now().Add(-time.Minute).Unix()
+	sign := func(telegram *Telegram, data string, owner int64, expires int64) string {
+		signed, err := telegram.signCallback(data, owner, expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	valid := sign(telegram, "stop:abc", operator, future)
+	long := "stop:" + strings.Repeat("a", maxCallbackData)
+	lost := strings.Join([]string{longCallbackPrefix + "0123456789abcdef", "1", strconv.FormatInt(future, 36)}, "|")
+
+	tests := []struct {
+		name    string
+		data    string
+		user    int64
+		want    string
+		wantErr error
+	}{
+		{"valid", valid, operator, "stop:abc", nil},
+		{"anyone", sign(telegram, "stop:abc", 0, future), operator, "stop:abc", nil},
+		{"bad signature", valid[:len(valid)-2] + "xx", operator, "", errCallbackInvalid},
+		{"tampered data", strings.Replace(valid, "stop:abc", "stop:abd", 1), operator, "", errCallbackInvalid},
+		{"other key", sign(newCallbackBot(t, "other"), "stop:abc", operator, future), operator, "", errCallbackInvalid},
+		{"unsigned", "stop:abc", operator, "", errCallbackInvalid},
+		{"expired", sign(telegram, "stop:abc", operator, past), operator, "", errCallbackExpired},
+		{"wrong owner", valid, viewer, "", errCallbackOwner},
+		{"role too low", sign(telegram, "stop:abc", 0, future), viewer, "", errCallbackRole},
+		{"unchecked action", sign(telegram, "confirm:token", 0, future), viewer, "confirm:token", nil},
+		{"long data", sign(telegram, long, operator, future), operator, long, nil},
+		{"long data role too low", sign(telegram, long, 0, future), viewer, "", errCallbackRole},
+		{"lost long data", lost + "|" + telegram.callbackSignature(lost), operator, "", errCallbackExpired},
+		{"data like a token", sign(telegram, longCallbackPrefix+"abc", operator, future), operator, longCallbackPrefix + "abc", nil},
+		{"long data of another bot", sign(newCallbackBot(t, "secret"), long, operator, future), operator, "", errCallbackExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &tb.Callback{Data: tt.data, Sender: &tb.User{ID: tt.user}, Message: &tb.Message{Chat: &tb.Chat{ID: tt.user}}}
+			got, err := telegram.verifyCallback(c)
+			if err != tt.wantErr {
+				t.Fatalf("verifyCallback() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("verifyCallback() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMenuKeepsLongButtons(t *testing.T) {
+	telegram := newCallbackBot(t, "secret")
+	menu := telegram.newMenu(1, [][]tb.InlineButton{
+		{{Text: "short", Data: "stop:abc"}, {Text: "long", Data: "navs:" + strings.Repeat("a", maxCallbackData)}},
+	})
+	if len(menu.InlineKeyboard) != 1 || len(menu.InlineKeyboard[0]) != 2 {
+		t.Fatalf("newMenu() kept %+v, want both buttons", menu.InlineKeyboard)
+	}
+	for _, button := range menu.InlineKeyboard[0] {
+		if len(button.Data) > maxCallbackData {
+			t.Errorf("button %v data %q is over %v bytes", button.Text, button.Data, maxCallbackData)
+		}
+	}
+	if strings.HasPrefix(menu.InlineKeyboard[0][0].Data, longCallbackPrefix) {
+		t.Errorf("short button data %q went to the store", menu.InlineKeyboard[0][0].Data)
+	}
+}
@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/constants"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// composeEditInterval is how often the progress message of a compose command is edited.
+	composeEditInterval = 2 * time.Second
+	// composeOutputSize is how much of the output tail the progress message shows.
+	composeOutputSize = 3000
+)
+
+// composeActions maps the compose callbacks to the arguments of their compose command.
+var composeActions = map[string][]string{
+	"composeup":     {"up", "-d"},
+	"composedown":   {"down"},
+	"composepull":   {"pull"},
+	"composeconfig": {"config"},
+}
+
+// handleCompose lists the compose projects, or runs a compose command on one. [project] [up|down|pull|config]
+func (t *Telegram) handleCompose(m *tb.Message) {
+	if len(t.cfg.Projects) == 0 {
+		t.reply(m, "No compose projects configured, add them to <code>projects</code> in <code>TELEDOCK_CONFIG</code>")
+		return
+	}
+	usage := "Usage: /compose <code>[project] [up|down|pull|config]</code>"
+	access := t.access(m.Chat, m.Sender)
+	args := strings.Fields(m.Payload)
+	if len(args) == 0 {
+		projects := t.formatProjects(access)
+		if len(projects) == 0 {
+			projects = []string{"No compose projects within your reach"}
+		}
+		t.reply(m, strings.Join(projects, "\n\n"))
+		return
+	}
+	if len(args) > 2 {
+		t.reply(m, usage)
+		return
+	}
+	project, ok := t.findProject(access, args[0])
+	if !ok {
+		t.reply(m, unknownProject(args[0]))
+		return
+	}
+	if len(args) == 1 {
+		t.reply(m, formatProject(project), t.makeComposeMenu(m.Sender.ID, project.Name))
+		return
+	}
+
+	instruction := "compose" + args[1]
+	if _, ok := composeActions[instruction]; !ok {
+		t.reply(m, usage)
+		return
+	}
+	if t.requireConfirmation(m, instruction, project.Name) {
+		return
+	}
+	message := t.reply(m, composeProgress(project, instruction, ""))
+	if message == nil {
+		return
+	}
+	if err := t.runCompose(message, project, instruction); err != nil {
+		t.auditError(messageKey(m), err)
+	}
+}
+
+// handleComposeCallback runs a compose command from the /compose menu, in the menu message.
+func (t *Telegram) handleComposeCallback(c *tb.Callback, instruction string, name string) {
+	project, ok := t.findProject(t.access(c.Message.Chat, c.Sender), name)
+	if !ok {
+		t.callbackResponse(c, nil, name, unknownProject(name))
+		return
+	}
+	t.callbackResponse(c, nil, name, composeProgress(project, instruction, ""))
+	if err := t.runCompose(c.Message, project, instruction); err != nil {
+		t.auditError(callbackKey(c), err)
+	}
+}
+
+// findProject returns the compose project called name when it is within reach of
+// access. Projects out of reach are reported as unknown so their existence isn't leaked.
+func (t *Telegram) findProject(access config.Access, name string) (config.Project, bool) {
+	project, ok := t.cfg.Projects[name]
+	if !ok || !access.AllowsProject(name) {
+		return config.Project{}, false
+	}
+	return project, true
+}
+
+func unknownProject(name string) string {
+	return fmt.Sprintf("Unknown compose project <code>%v</code>", html.EscapeString(name))
+}
+
+// makeComposeMenu builds the compose commands keyboard of a project.
+func (t *Telegram) makeComposeMenu(owner int64, name string) *tb.ReplyMarkup {
+	return t.newMenu(owner, [][]tb.InlineButton{{
+		{Text: "Up", Data: fmt.Sprintf("composeup:%v", name)},
+		{Text: "Pull", Data: fmt.Sprintf("composepull:%v", name)},
+		{Text: "Down", Data: fmt.Sprintf("composedown:%v", name)},
+		{Text: "Config", Data: fmt.Sprintf("composeconfig:%v", name)},
+	}})
+}
+
+// runCompose runs the compose command of instruction on project, editing message
+// with its output as it goes and with the result once done. Output too long for
+// the message is sent as a file too.
+func (t *Telegram) runCompose(message *tb.Message, project config.Project, instruction string) error {
+	var (
+		mu     sync.Mutex
+		output string
+		dirty  bool
+		wg     sync.WaitGroup
+	)
+	progress := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		output, dirty = tail(output+line+"\n", composeOutputSize), true
+	}
+
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(composeEditInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				text, changed := composeProgress(project, instruction, output), dirty
+				dirty = false
+				mu.Unlock()
+				if changed {
+					t.editCompose(message, text)
+				}
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.cfg.ComposeTimeout)
+	defer cancel()
+	result, err := t.composer.Run(ctx, project, composeActions[instruction], progress)
+	close(done)
+	wg.Wait()
+
+	command := html.EscapeString(strings.Join(composeActions[instruction], " "))
+	text := fmt.Sprintf("%v <code>compose %v</code> on <b>%v</b> done", emoji.CheckMarkButton, command, project.Name)
+	if err != nil {
+		log.Error().Err(err).Str("project", project.Name).Msg("compose command failed")
+		text = fmt.Sprintf("%v <code>compose %v</code> on <b>%v</b> failed: %v", emoji.CrossMark, command, project.Name, html.EscapeString(err.Error()))
+	}
+	if trimmed := strings.TrimSpace(result); trimmed != "" {
+		text = fmt.Sprintf("%v\n<pre>%v</pre>", text, html.EscapeString(tail(trimmed, composeOutputSize)))
+	}
+	t.editCompose(message, text)
+
+	if len([]rune(result)) > composeOutputSize {
+		lines := strings.Split(strings.TrimSuffix(result, "\n"), "\n")
+		t.send(message.Chat, t.makeDocument(fmt.Sprintf("%v-%v.txt", project.Name, composeActions[instruction][0]), fmt.Sprintf("Output of compose %v on %v", command, project.Name), lines))
+	}
+	return err
+}
+
+func (t *Telegram) editCompose(message *tb.Message, text string) {
+	if _, err := t.bot.Edit(message, text, tb.ModeHTML); err != nil && !isNotModified(err) {
+		log.Warn().Err(err).Msg("error editing compose message")
+	}
+}
+
+// composeProgress is the text of a running compose command with the tail of its output.
+func composeProgress(project config.Project, instruction string, output string) string {
+	text := fmt.Sprintf("%v Running <code>compose %v</code> on <b>%v</b>...", emoji.HourglassNotDone, html.EscapeString(strings.Join(composeActions[instruction], " ")), project.Name)
+	if output = strings.TrimSpace(output); output != "" {
+		text = fmt.Sprintf("%v\n<pre>%v</pre>", text, html.EscapeString(output))
+	}
+	return text
+}
+
+// formatProjects describes the compose projects within reach of access, sorted by name.
+func (t *Telegram) formatProjects(access config.Access) []string {
+	names := make([]string, 0, len(t.cfg.Projects))
+	for name := range t.cfg.Projects {
+		if access.AllowsProject(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	resultMsg := make([]string, 0, len(names))
+	for _, name := range names {
+		resultMsg = append(resultMsg, formatProject(t.cfg.Projects[name]))
+	}
+	return resultMsg
+}
+
+func formatProject(project config.Project) string {
+	message := []string{
+		fmt.Sprintf("<b>%v</b>", project.Name),
+		fmt.Sprintf(constants.FormatedStrPadded, "DIR:", html.EscapeString(project.Dir)),
+	}
+	if len(project.Files) > 0 {
+		message = append(message, fmt.Sprintf(constants.FormatedStrPadded, "FILES:", html.EscapeString(strings.Join(project.Files, ", "))))
+	}
+	return strings.Join(message, "\n")
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// composeConfig has the shop and blog projects, an admin, user 1, and an operator
+// limited to the shop project, user 2.
+func composeConfig() *config.Config {
+	return &config.Config{
+		Users: []config.User{
+			{ID: 1, Role: config.RoleAdmin},
+			{ID: 2, Role: config.RoleOperator, Scopes: []string{"shop"}},
+		},
+		Scopes:   map[string]config.Scope{"shop": {Projects: []string{"shop"}}},
+		Commands: map[string]config.Role{"compose": config.RoleOperator},
+		Projects: map[string]config.Project{
+			"shop": {Name: "shop", Dir: "/srv/shop"},
+			"blog": {Name: "blog", Dir: "/srv/blog"},
+		},
+		CallbackTimeout: time.Hour,
+		ComposeTimeout:  time.Minute,
+	}
+}
+
+func TestHandleComposeScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    int64
+		payload string
+		want    string
+		wantNot string
+	}{
+		{"admin lists every project", 1, "", "blog", ""},
+		{"operator lists its projects", 2, "", "shop", "blog"},
+		{"operator opens its project", 2, "shop", "shop", ""},
+		{"operator can't open other projects", 2, "blog", "Unknown compose project", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := newFakeBot()
+			telegram, err := New(composeConfig(), bot, docker.NewFake(), compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			telegram.handleCompose(&tb.Message{Chat: &tb.Chat{ID: tt.user}, Sender: &tb.User{ID: tt.user}, Payload: tt.payload})
+			text := bot.last().Text
+			if !strings.Contains(text, tt.want) || (tt.wantNot != "" && strings.Contains(text, tt.wantNot)) {
+				t.Errorf("/compose %v = %q, want %q and no %q in it", tt.payload, text, tt.want, tt.wantNot)
+			}
+		})
+	}
+}
+
+func TestComposeCallbackScope(t *testing.T) {
+	bot := newFakeBot()
+	composer := compose.NewFake()
+	telegram, err := New(composeConfig(), bot, docker.NewFake(), composer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := telegram.signCallback("composeup:blog", 2, time.Now().Add(time.Hour).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := &tb.Chat{ID: 2}
+	telegram.handleCallback(&tb.Callback{ID: "1", Sender: &tb.User{ID: 2}, Message: &tb.Message{ID: 1, Chat: chat}, Data: data})
+	if text := bot.last().Text; !strings.Contains(text, "Unknown compose project") || len(composer.Calls) != 0 {
+		t.Errorf("operator pressing up on blog got %q and ran %v, want it unknown", text, composer.Calls)
+	}
+}
+
+func TestComposeDownSelfAsksConfirmation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		confirm bool
+	}{
+		{"own project", "shop down", true},
+		{"other project", "blog down", false},
+		{"own project up", "shop pull", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := docker.NewFake().AddStack("shop", "teledock", "web").AddStack("blog", "web")
+			engine.Self = engine.Containers[0].ID
+			bot := newFakeBot()
+			composer := compose.NewFake()
+			telegram, err := New(composeConfig(), bot, engine, composer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			telegram.handleCompose(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}, Payload: tt.payload})
+			asked := strings.Contains(bot.last().Text, "the bot will stop answering")
+			if asked != tt.confirm || (len(composer.Calls) == 0) != tt.confirm {
+				t.Errorf("/compose %v asked %v with calls %v, want asked %v", tt.payload, asked, composer.Calls, tt.confirm)
+			}
+		})
+	}
+}
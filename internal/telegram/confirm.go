@@ -0,0 +1,169 @@
+package telegram
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enescakir/emoji"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+var (
+	errConfirmationExpired = errors.New("this confirmation expired, run the command again")
+	errConfirmationOwner   = errors.New("only the user who asked can answer")
+)
+
+// pendingAction is a destructive action waiting for its requester to confirm it.
+type pendingAction struct {
+	owner   int64
+	data    string
+	expires time.Time
+}
+
+// confirmRegistry keeps the pending confirmations by token.
+type confirmRegistry struct {
+	mu      sync.Mutex
+	pending map[string]*pendingAction
+}
+
+func newConfirmRegistry() *confirmRegistry {
+	return &confirmRegistry{pending: map[string]*pendingAction{}}
+}
+
+// add stores the callback data to run once owner confirms and returns its token.
+func (r *confirmRegistry) add(owner int64, data string, ttl time.Duration) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for token, action := range r.pending {
+		if time.Now().After(action.expires) {
+			delete(r.pending, token)
+		}
+	}
+
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		log.Error().Err(err).Msg("error generating confirmation token")
+	}
+	token := hex.EncodeToString(bytes)
+	r.pending[token] = &pendingAction{owner: owner, data: data, expires: time.Now().Add(ttl)}
+	return token
+}
+
+// take consumes the token answered by userID and returns the callback data it stands for.
+func (r *confirmRegistry) take(token string, userID int64) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	action, ok := r.pending[token]
+	if !ok || time.Now().After(action.expires) {
+		delete(r.pending, token)
+		return "", errConfirmationExpired
+	}
+	if action.owner != userID {
+		return "", errConfirmationOwner
+	}
+	delete(r.pending, token)
+	return action.data, nil
+}
+
+// needsConfirmation reports whether action on containerID has to be confirmed first.
+// Destructive actions on teledock's own container, or its stack, always do. Stack wide actions
+// confirm like their container action, target being the stack name, compose
+// commands like their name, target being the project, and the container actions
+// of the navigator like the command they stand for.
+func (t *Telegram) needsConfirmation(action string, target string) bool {
+	if args, ok := composeActions[action]; ok {
+		return t.isConfirmAction(args[0]) || (destructiveActions[args[0]] && t.stackHoldsSelf(target))
+	}
+	if containerAction, ok := stackActions[action]; ok {
+		return t.isConfirmAction(containerAction) || (destructiveActions[containerAction] && t.stackHoldsSelf(target))
+	}
+	if containerAction, ok := navigatorActions[action]; ok {
+		action = containerAction
+	}
+	return t.isConfirmAction(action) || (destructiveActions[action] && t.dckr.IsSelf(target))
+}
+
+// isConfirmAction reports whether action is set to ask for confirmation.
+func (t *Telegram) isConfirmAction(action string) bool {
+	for _, confirm := range t.cfg.ConfirmActions {
+		if confirm == action {
+			return true
+		}
+	}
+	return false
+}
+
+// requireConfirmation replies with a confirmation prompt when action needs one.
+// It reports whether the action has to wait for it.
+func (t *Telegram) requireConfirmation(m *tb.Message, action string, containerID string, args ...string) bool {
+	if !t.needsConfirmation(action, containerID) {
+		return false
+	}
+	t.reply(m, t.confirmationText(action, containerID), t.makeConfirmMenu(m.Sender, action, containerID, args...))
+	return true
+}
+
+// makeConfirmMenu builds the Yes/No keyboard of an action, only answerable by user.
+func (t *Telegram) makeConfirmMenu(user *tb.User, action string, containerID string, args ...string) *tb.ReplyMarkup {
+	data := strings.Join(append([]string{action, containerID}, args...), ":")
+	token := t.confirms.add(user.ID, data, t.cfg.ConfirmTimeout)
+
+	return t.newMenu(user.ID, [][]tb.InlineButton{{
+		{Text: "Yes", Data: fmt.Sprintf("confirm:%v", token)},
+		{Text: "No", Data: fmt.Sprintf("reject:%v", token)},
+	}})
+}
+
+func (t *Telegram) confirmationText(action string, containerID string) string {
+	if args, ok := composeActions[action]; ok {
+		text := fmt.Sprintf("Are you sure you want to run <code>compose %v</code> on the project <code>%v</code>?", strings.Join(args, " "), html.EscapeString(containerID))
+		if destructiveActions[args[0]] && t.stackHoldsSelf(containerID) {
+			text = fmt.Sprintf("%v teledock runs in this project, if you take it %v the bot will stop answering.\n%v", emoji.Warning, args[0], text)
+		}
+		return fmt.Sprintf("%v\n<i>This question expires in %v</i>", text, t.cfg.ConfirmTimeout)
+	}
+	if containerAction, ok := stackActions[action]; ok {
+		text := fmt.Sprintf("Are you sure you want to %v every container of the stack <code>%v</code>?", containerAction, html.EscapeString(containerID))
+		if destructiveActions[containerAction] && t.stackHoldsSelf(containerID) {
+			text = fmt.Sprintf("%v teledock runs in this stack, if you %v it the bot will stop answering.\n%v", emoji.Warning, containerAction, text)
+		}
+		return fmt.Sprintf("%v\n<i>This question expires in %v</i>", text, t.cfg.ConfirmTimeout)
+	}
+
+	if containerAction, ok := navigatorActions[action]; ok {
+		action = containerAction
+	}
+	text := fmt.Sprintf("Are you sure you want to %v <code>%v</code>?", action, containerID)
+	if destructiveActions[action] && t.dckr.IsSelf(containerID) {
+		text = fmt.Sprintf("%v This is the container teledock runs in, if you %v it the bot will stop answering.\n%v", emoji.Warning, action, text)
+	}
+	return fmt.Sprintf("%v\n<i>This question expires in %v</i>", text, t.cfg.ConfirmTimeout)
+}
+
+// handleConfirmation answers the Yes/No buttons of a confirmation prompt.
+func (t *Telegram) handleConfirmation(c *tb.Callback, token string, accepted bool) {
+	data, err := t.confirms.take(token, c.Sender.ID)
+	if err == errConfirmationOwner {
+		t.alert(c, err.Error())
+		return
+	}
+	if err != nil {
+		t.callbackResponse(c, nil, token, err.Error())
+		return
+	}
+	if !accepted {
+		t.callbackResponse(c, nil, token, "Cancelled")
+		return
+	}
+
+	parts := strings.Split(data, ":")
+	t.runCallback(c, parts[0], parts[1], parts[2:], true)
+}
@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestStopSelfAsksConfirmation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		confirm bool
+	}{
+		{"own container", "aaa000000000", true},
+		{"other container", "bbb000000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := docker.NewFake().
+				AddContainer("aaa", "teledock", "teledock", "running", nil).
+				AddContainer("bbb", "web", "nginx", "running", nil)
+			engine.Self = engine.Containers[0].ID
+			bot := newFakeBot()
+			telegram, err := New(&config.Config{Users: []config.User{{ID: 1, Role: config.RoleAdmin}}}, bot, engine, compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			telegram.handleStop(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}, Payload: tt.payload})
+			asked := strings.Contains(bot.last().Text, "the bot will stop answering")
+			if asked != tt.confirm || (len(engine.Calls) == 0) != tt.confirm {
+				t.Errorf("/stop %v asked %v with calls %v, want asked %v", tt.payload, asked, engine.Calls, tt.confirm)
+			}
+		})
+	}
+}
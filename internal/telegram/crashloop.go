@@ -0,0 +1,105 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// crashLoopLogSize is how much of the log tail a crash loop alert shows.
+const crashLoopLogSize = 1500
+
+// crashLoop is the recent deaths of a container.
+type crashLoop struct {
+	deaths  []docker.Event
+	alerted time.Time
+}
+
+// crashDetector spots containers dying restarts times within window, so they are
+// reported once instead of on every death. It is only used by watchEvents.
+type crashDetector struct {
+	restarts int
+	window   time.Duration
+	loops    map[string]*crashLoop
+}
+
+func newCrashDetector(restarts int, window time.Duration) *crashDetector {
+	return &crashDetector{restarts: restarts, window: window, loops: map[string]*crashLoop{}}
+}
+
+// observe records event and reports whether it is part of a crash loop, a death
+// or restart after the first that the chats getting crash loop alerts don't need
+// on its own. When the container just started crash looping it also returns its
+// recent deaths.
+func (d *crashDetector) observe(event docker.Event) (bool, []docker.Event) {
+	d.forget(event.Time)
+
+	loop, ok := d.loops[event.ContainerID]
+	if !ok {
+		if event.Action != docker.EventDie {
+			return false, nil
+		}
+		loop = &crashLoop{}
+		d.loops[event.ContainerID] = loop
+	}
+
+	switch event.Action {
+	case docker.EventDie:
+		loop.deaths = append(loop.deaths, event)
+		if len(loop.deaths) >= d.restarts && event.Time.Sub(loop.alerted) > d.window {
+			loop.alerted = event.Time
+			return true, append([]docker.Event{}, loop.deaths...)
+		}
+		return len(loop.deaths) > 1, nil
+	case docker.EventStart, docker.EventRestart:
+		// Starts after the first death are the restart policy bringing it back.
+		return len(loop.deaths) > 1, nil
+	}
+	return false, nil
+}
+
+// forget drops the deaths that happened longer than window before now, and the
+// containers left without any.
+func (d *crashDetector) forget(now time.Time) {
+	for containerID, loop := range d.loops {
+		recent := []docker.Event{}
+		for _, death := range loop.deaths {
+			if now.Sub(death.Time) < d.window {
+				recent = append(recent, death)
+			}
+		}
+		if len(recent) == 0 {
+			delete(d.loops, containerID)
+			continue
+		}
+		loop.deaths = recent
+	}
+}
+
+// notifyCrashLoop sends one alert with the exit codes and last logs of a crash looping container.
+func (t *Telegram) notifyCrashLoop(event docker.Event, deaths []docker.Event) {
+	exitCodes := make([]string, len(deaths))
+	for index, death := range deaths {
+		exitCodes[index] = death.ExitCode
+	}
+	text := fmt.Sprintf("%v <code>%v</code> died %v times in the last %v\nExit codes: %v",
+		emoji.Fire, html.EscapeString(event.Name), len(deaths), t.cfg.CrashLoopWindow, html.EscapeString(strings.Join(exitCodes, ", ")))
+
+	if logs, err := t.dckr.Logs(event.ContainerID, docker.LogsOptions{Tail: "10"}); err == nil {
+		output := tail(strings.Join(formatLogs(logs), "\n"), crashLoopLogSize)
+		text = fmt.Sprintf("%v\n<pre>%v</pre>", text, html.EscapeString(output))
+	}
+
+	containerID := shortID(event.ContainerID)
+	menu := t.newMenu(0, [][]tb.InlineButton{{
+		{Text: "Stop", Data: fmt.Sprintf("stop:%v", containerID)},
+		{Text: "Disable restart", Data: fmt.Sprintf("norestart:%v", containerID)},
+		{Text: "Logs", Data: fmt.Sprintf("logs:%v", containerID)},
+	}})
+	t.notifyChats("crashloop", event, text, menu, false)
+}
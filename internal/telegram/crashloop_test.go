@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/docker"
+)
+
+func TestCrashDetectorObserve(t *testing.T) {
+	start := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes float64) time.Time {
+		return start.Add(time.Duration(minutes * float64(time.Minute)))
+	}
+	type step struct {
+		action  string
+		minutes float64
+		looping bool
+		deaths  int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		// loops is how many containers the detector still tracks at the end.
+		loops int
+	}{
+		{
+			name: "single death",
+			steps: []step{
+				{docker.EventDie, 0, false, 0},
+				{docker.EventStart, 0.1, false, 0},
+			},
+			loops: 1,
+		},
+		{
+			name: "crash loop is reported once",
+			steps: []step{
+				{docker.EventDie, 0, false, 0},
+				{docker.EventStart, 0.1, false, 0},
+				{docker.EventDie, 1, true, 0},
+				{docker.EventStart, 1.1, true, 0},
+				{docker.EventDie, 2, true, 3},
+				{docker.EventStart, 2.1, true, 0},
+				{docker.EventDie, 3, true, 0},
+			},
+			loops: 1,
+		},
+		{
+			name: "deaths out of the window are forgotten",
+			steps: []step{
+				{docker.EventDie, 0, false, 0},
+				{docker.EventDie, 4, true, 0},
+				{docker.EventDie, 8, true, 0},
+				{docker.EventDie, 20, false, 0},
+			},
+			loops: 1,
+		},
+		{
+			name: "reported again after the window",
+			steps: []step{
+				{docker.EventDie, 0, false, 0},
+				{docker.EventDie, 1, true, 0},
+				{docker.EventDie, 2, true, 3},
+				{docker.EventDie, 8, false, 0},
+				{docker.EventDie, 9, true, 0},
+				{docker.EventDie, 10, true, 3},
+			},
+			loops: 1,
+		},
+		{
+			name: "other events pass through",
+			steps: []step{
+				{docker.EventStart, 0, false, 0},
+				{docker.EventOOM, 1, false, 0},
+			},
+		},
+		{
+			name: "forgotten once the window expired",
+			steps: []step{
+				{docker.EventDie, 0, false, 0},
+				{docker.EventStart, 6, false, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := newCrashDetector(3, 5*time.Minute)
+			for index, step := range tt.steps {
+				looping, deaths := detector.observe(docker.Event{Action: step.action, ContainerID: "abc", Time: at(step.minutes)})
+				if looping != step.looping || len(deaths) != step.deaths {
+					t.Fatalf("step %v (%v at %vm) = %v with %v deaths, want %v with %v", index, step.action, step.minutes, looping, len(deaths), step.looping, step.deaths)
+				}
+			}
+			if len(detector.loops) != tt.loops {
+				t.Errorf("tracking %v containers, want %v", len(detector.loops), tt.loops)
+			}
+		})
+	}
+}
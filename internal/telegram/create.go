@@ -0,0 +1,390 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/audit"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/constants"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// wizardTimeout drops /create flows left unfinished for this long.
+const wizardTimeout = 10 * time.Minute
+
+// maxImageButtons caps the images offered as buttons, others can still be typed.
+const maxImageButtons = 20
+
+var containerNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+type createStep int
+
+const (
+	stepImage createStep = iota
+	stepName
+	stepPorts
+	stepEnv
+	stepVolumes
+	stepRestart
+	stepConfirm
+)
+
+// createWizard walks an admin through the settings of a new container.
+type createWizard struct {
+	mu      sync.Mutex
+	owner   int64
+	step    createStep
+	spec    docker.ContainerSpec
+	images  []string
+	message *tb.Message
+	updated time.Time
+	// problem is shown along the current prompt when the last answer was invalid.
+	problem string
+}
+
+// wizardRegistry keeps track of the /create flow of every chat.
+type wizardRegistry struct {
+	mu      sync.Mutex
+	wizards map[int64]*createWizard
+}
+
+func newWizardRegistry() *wizardRegistry {
+	return &wizardRegistry{wizards: map[int64]*createWizard{}}
+}
+
+// get returns the unexpired wizard of the chat owned by userID.
+func (r *wizardRegistry) get(chatID, userID int64) *createWizard {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wizard, ok := r.wizards[chatID]
+	if !ok || wizard.owner != userID {
+		return nil
+	}
+	if time.Since(wizard.updated) > wizardTimeout {
+		delete(r.wizards, chatID)
+		return nil
+	}
+	return wizard
+}
+
+func (r *wizardRegistry) set(chatID int64, wizard *createWizard) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wizards[chatID] = wizard
+}
+
+func (r *wizardRegistry) remove(chatID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.wizards, chatID)
+}
+
+// handleCreate starts the /create flow.
+func (t *Telegram) handleCreate(m *tb.Message) {
+	images, err := t.listImages(t.access(m.Chat, m.Sender))
+	if err != nil {
+		t.replyError(m, err)
+		return
+	}
+
+	wizard := &createWizard{owner: m.Sender.ID, step: stepImage, images: imageTags(images), updated: time.Now()}
+	if image := strings.TrimSpace(m.Payload); image != "" {
+		wizard.spec.Image = image
+		wizard.step = stepName
+	}
+	text, menu := t.renderWizard(wizard)
+	wizard.message = t.reply(m, text, menu)
+	if wizard.message != nil {
+		t.wizards.set(m.Chat.ID, wizard)
+	}
+}
+
+// handleCreateInput feeds a plain text message to the /create flow of its chat.
+// It reports whether the message was consumed by a wizard.
+func (t *Telegram) handleCreateInput(m *tb.Message) bool {
+	wizard := t.wizards.get(m.Chat.ID, m.Sender.ID)
+	if wizard == nil {
+		return false
+	}
+
+	wizard.mu.Lock()
+	defer wizard.mu.Unlock()
+
+	input := strings.TrimSpace(m.Text)
+	if strings.EqualFold(input, "skip") {
+		input = ""
+	}
+	if wizard.step >= stepRestart {
+		return true
+	}
+	wizard.problem = ""
+	if err := wizard.apply(input); err != nil {
+		wizard.problem = err.Error()
+	} else {
+		wizard.step++
+	}
+	t.updateWizard(wizard)
+	return true
+}
+
+// apply stores the answer to the current step.
+func (w *createWizard) apply(input string) error {
+	values := splitList(input, w.step != stepEnv)
+	switch w.step {
+	case stepImage:
+		if input == "" {
+			return fmt.Errorf("an image is required")
+		}
+		w.spec.Image = input
+	case stepName:
+		if input != "" && !containerNameRe.MatchString(input) {
+			return fmt.Errorf("invalid container name %q", input)
+		}
+		w.spec.Name = input
+	case stepPorts:
+		if err := (docker.ContainerSpec{Image: w.spec.Image, Ports: values}).Validate(); err != nil {
+			return err
+		}
+		w.spec.Ports = values
+	case stepEnv:
+		if err := (docker.ContainerSpec{Image: w.spec.Image, Env: values}).Validate(); err != nil {
+			return err
+		}
+		w.spec.Env = values
+	case stepVolumes:
+		if err := (docker.ContainerSpec{Image: w.spec.Image, Volumes: values}).Validate(); err != nil {
+			return err
+		}
+		w.spec.Volumes = values
+	}
+	return nil
+}
+
+// handleCreateCallback handles the buttons of the /create flow.
+func (t *Telegram) handleCreateCallback(c *tb.Callback, action string, args []string) {
+	wizard := t.wizards.get(c.Message.Chat.ID, c.Sender.ID)
+	if wizard == nil {
+		t.callbackResponse(c, nil, "", "This container creation expired, start again with /create")
+		return
+	}
+	wizard.mu.Lock()
+	defer wizard.mu.Unlock()
+
+	wizard.problem = ""
+	if err := t.bot.Respond(c, &tb.CallbackResponse{}); err != nil {
+		log.Error().Err(err).Msg("error replying to callback")
+	}
+
+	switch action {
+	case "image":
+		index, err := strconv.Atoi(strings.Join(args, ""))
+		if err != nil || index < 0 || index >= len(wizard.images) {
+			return
+		}
+		wizard.spec.Image = wizard.images[index]
+		wizard.step = stepName
+	case "skip":
+		if wizard.step != stepImage && wizard.step < stepRestart {
+			if err := wizard.apply(""); err == nil {
+				wizard.step++
+			}
+		}
+	case "restart":
+		if len(args) == 0 {
+			return
+		}
+		wizard.spec.RestartPolicy = args[0]
+		wizard.step = stepConfirm
+	case "cancel":
+		t.wizards.remove(c.Message.Chat.ID)
+		t.editWizard(wizard, "Container creation cancelled", nil)
+		return
+	case "confirm":
+		if step, err := checkSpecScope(t.access(c.Message.Chat, c.Sender), wizard.spec); err != nil {
+			wizard.problem, wizard.step = err.Error(), step
+			break
+		}
+		t.wizards.remove(c.Message.Chat.ID)
+		t.createContainer(c, wizard, len(args) > 0 && args[0] == "start")
+		return
+	}
+	t.updateWizard(wizard)
+}
+
+// checkSpecScope fails when a scoped access would create a container out of its
+// reach, or reach out of its scopes through a host path. It returns the step to
+// fix it at.
+func checkSpecScope(access config.Access, spec docker.ContainerSpec) (createStep, error) {
+	if access.Unrestricted() {
+		return stepConfirm, nil
+	}
+	if spec.Name == "" || !access.Allows(spec.Name, nil) {
+		return stepName, fmt.Errorf("the container would be out of your reach, give it a name matching your scopes")
+	}
+	for _, volume := range spec.Volumes {
+		if strings.HasPrefix(volume, "/") {
+			return stepVolumes, fmt.Errorf("host paths can't be mounted from a scope, use named volumes")
+		}
+	}
+	return stepConfirm, nil
+}
+
+func (t *Telegram) createContainer(c *tb.Callback, wizard *createWizard, start bool) {
+	containerID, err := t.dckr.Create(wizard.spec)
+	if err != nil {
+		t.auditError(callbackKey(c), err)
+		t.editWizard(wizard, formatError(err), nil)
+		return
+	}
+	t.audits.update(callbackKey(c), func(entry *audit.Entry) {
+		entry.Target, entry.Args = containerID[:12], wizard.spec.Image
+	})
+	result := fmt.Sprintf("Container <code>%v</code> created", containerID[:12])
+	if start {
+		if err := t.dckr.Start(containerID); err != nil {
+			t.auditError(callbackKey(c), err)
+			result = fmt.Sprintf("%v but failed to start\n%v", result, formatError(err))
+		} else {
+			result = fmt.Sprintf("Container <code>%v</code> created and started", containerID[:12])
+		}
+	}
+	t.editWizard(wizard, result, nil)
+}
+
+func (t *Telegram) updateWizard(wizard *createWizard) {
+	wizard.updated = time.Now()
+	text, menu := t.renderWizard(wizard)
+	t.editWizard(wizard, text, menu)
+}
+
+func (t *Telegram) editWizard(wizard *createWizard, text string, menu *tb.ReplyMarkup) {
+	options := []interface{}{tb.ModeHTML}
+	if menu != nil {
+		options = append(options, menu)
+	}
+	if _, err := t.bot.Edit(wizard.message, text, options...); err != nil && !isNotModified(err) {
+		log.Error().Err(err).Msg("error editing message")
+	}
+}
+
+// renderWizard returns the prompt and keyboard of the current step.
+func (t *Telegram) renderWizard(wizard *createWizard) (string, *tb.ReplyMarkup) {
+	skip := tb.InlineButton{Text: "Skip", Data: "create:skip"}
+	cancel := tb.InlineButton{Text: "Cancel", Data: "create:cancel"}
+	rows := [][]tb.InlineButton{}
+
+	var prompt string
+	switch wizard.step {
+	case stepImage:
+		prompt = "<b>1/6</b> Choose an installed image or send its name"
+		row := []tb.InlineButton{}
+		for index, image := range wizard.images {
+			if index == maxImageButtons {
+				break
+			}
+			if len(row) == 2 {
+				rows = append(rows, row)
+				row = nil
+			}
+			row = append(row, tb.InlineButton{Text: image, Data: fmt.Sprintf("create:image:%v", index)})
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+		rows = append(rows, []tb.InlineButton{cancel})
+	case stepName:
+		prompt = "<b>2/6</b> Send the container name"
+		rows = append(rows, []tb.InlineButton{skip, cancel})
+	case stepPorts:
+		prompt = "<b>3/6</b> Send the ports to publish, one per line\n<code>8080:80</code>"
+		rows = append(rows, []tb.InlineButton{skip, cancel})
+	case stepEnv:
+		prompt = "<b>4/6</b> Send the environment variables, one per line\n<code>KEY=value</code>"
+		rows = append(rows, []tb.InlineButton{skip, cancel})
+	case stepVolumes:
+		prompt = "<b>5/6</b> Send the volumes to mount, one per line\n<code>/host/path:/container/path</code>"
+		rows = append(rows, []tb.InlineButton{skip, cancel})
+	case stepRestart:
+		prompt = "<b>6/6</b> Choose the restart policy"
+		row := []tb.InlineButton{}
+		for _, policy := range docker.RestartPolicies {
+			row = append(row, tb.InlineButton{Text: policy, Data: fmt.Sprintf("create:restart:%v", policy)})
+		}
+		rows = append(rows, row, []tb.InlineButton{cancel})
+	case stepConfirm:
+		prompt = "Create this container?"
+		rows = append(rows, []tb.InlineButton{
+			{Text: "Create", Data: "create:confirm"},
+			{Text: "Create and start", Data: "create:confirm:start"},
+		}, []tb.InlineButton{cancel})
+	}
+	menu := t.newMenu(wizard.owner, rows)
+
+	message := []string{formatSpec(wizard.spec), prompt}
+	if wizard.problem != "" {
+		message = append(message, fmt.Sprintf("%v %v", emoji.Warning, html.EscapeString(wizard.problem)))
+	}
+	return strings.Join(message, "\n\n"), menu
+}
+
+// formatSpec lists the settings chosen so far.
+func formatSpec(spec docker.ContainerSpec) string {
+	orNone := func(values []string) string {
+		if len(values) == 0 {
+			return "-"
+		}
+		return html.EscapeString(strings.Join(values, ", "))
+	}
+	name, restart := spec.Name, spec.RestartPolicy
+	if name == "" {
+		name = "-"
+	}
+	if restart == "" {
+		restart = "-"
+	}
+	return strings.Join([]string{
+		"<b>New container</b>",
+		fmt.Sprintf(constants.FormatedStrPadded, "IMAGE:", html.EscapeString(spec.Image)),
+		fmt.Sprintf(constants.FormatedStrPadded, "NAME:", html.EscapeString(name)),
+		fmt.Sprintf(constants.FormatedStrPadded, "PORTS:", orNone(spec.Ports)),
+		fmt.Sprintf(constants.FormatedStrPadded, "ENV:", orNone(spec.Env)),
+		fmt.Sprintf(constants.FormatedStrPadded, "VOLUMES:", orNone(spec.Volumes)),
+		fmt.Sprintf(constants.FormatedStrPadded, "RESTART:", restart),
+	}, "\n")
+}
+
+// imageTags returns the usable tags of the images, skipping dangling ones.
+func imageTags(images []types.ImageSummary) []string {
+	tags := []string{}
+	for _, image := range images {
+		for _, tag := range image.RepoTags {
+			if tag != "<none>:<none>" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// splitList splits user input on new lines, and commas if commas is set, dropping empty items.
+func splitList(input string, commas bool) []string {
+	values := []string{}
+	for _, value := range strings.FieldsFunc(input, func(r rune) bool { return r == '\n' || (commas && r == ',') }) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,32 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/docker"
+)
+
+func TestCheckSpecScope(t *testing.T) {
+	cfg := scopedConfig()
+	tests := []struct {
+		name    string
+		user    int64
+		spec    docker.ContainerSpec
+		step    createStep
+		wantErr bool
+	}{
+		{"unscoped", 1, docker.ContainerSpec{Image: "nginx", Volumes: []string{"/:/host"}}, stepConfirm, false},
+		{"in scope", 2, docker.ContainerSpec{Image: "nginx", Name: "shop-web", Volumes: []string{"data:/data"}}, stepConfirm, false},
+		{"no name", 2, docker.ContainerSpec{Image: "nginx"}, stepName, true},
+		{"out of scope", 2, docker.ContainerSpec{Image: "nginx", Name: "blog-web"}, stepName, true},
+		{"host path", 2, docker.ContainerSpec{Image: "nginx", Name: "shop-web", Volumes: []string{"/var/run/docker.sock:/var/run/docker.sock"}}, stepVolumes, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, err := checkSpecScope(cfg.Access(tt.user, tt.user), tt.spec)
+			if step != tt.step || (err != nil) != tt.wantErr {
+				t.Errorf("checkSpecScope() = %v, %v, want step %v and an error %v", step, err, tt.step, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// formatError turns an engine error into a message fit for the user.
+func formatError(err error) string {
+	var dErr *docker.Error
+	target := "The container"
+	if errors.As(err, &dErr) && dErr.ContainerID != "" {
+		target = fmt.Sprintf("Container <code>%v</code>", html.EscapeString(dErr.ContainerID))
+		if dErr.Op == "stack" {
+			target = fmt.Sprintf("Stack <code>%v</code>", html.EscapeString(dErr.ContainerID))
+		}
+	}
+
+	switch {
+	case errors.Is(err, docker.ErrNotFound):
+		return fmt.Sprintf("%v %v does not exist", emoji.MagnifyingGlassTiltedLeft, target)
+	case errors.Is(err, docker.ErrConflict):
+		return fmt.Sprintf("%v %v can't do that right now: %v", emoji.Warning, target, html.EscapeString(cause(err)))
+	case errors.Is(err, docker.ErrTimeout):
+		return fmt.Sprintf("%v %v took too long to answer, the operation was aborted", emoji.HourglassDone, target)
+	case errors.Is(err, docker.ErrUnavailable):
+		return fmt.Sprintf("%v The docker daemon is unreachable, try again in a moment", emoji.ElectricPlug)
+	case errors.Is(err, docker.ErrPermissionDenied):
+		return fmt.Sprintf("%v Teledock is not allowed to talk to the docker daemon", emoji.NoEntry)
+	}
+	return fmt.Sprintf("%v Something went wrong: %v", emoji.CrossMark, html.EscapeString(cause(err)))
+}
+
+// cause returns the message of the innermost error wrapped by an engine error.
+func cause(err error) string {
+	var dErr *docker.Error
+	if errors.As(err, &dErr) && dErr.Err != nil {
+		return dErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// isNotModified reports whether an edit failed only because the content did not change.
+func isNotModified(err error) bool {
+	return err == tb.ErrMessageNotModified || err == tb.ErrSameMessageContent
+}
@@ -0,0 +1,50 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestHandleExec(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		command string
+		result  *docker.ExecResult
+		want    string
+	}{
+		{"exit code", "aaa000000000 false", "false", &docker.ExecResult{ExitCode: 1}, "Exit code: 1"},
+		{"truncated", "aaa000000000 yes", "yes", &docker.ExecResult{Stdout: "y\n", Truncated: true}, "got truncated"},
+		{"timeout", "aaa000000000 sleep 60", "sleep 60", nil, "may still be running"},
+		{"usage", "aaa000000000", "", nil, "Usage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := docker.NewFake().AddContainer("aaa", "web", "nginx", "running", nil)
+			if tt.command != "" {
+				engine.ExecResults[tt.command] = tt.result
+			}
+			bot := newFakeBot()
+			cfg := &config.Config{Users: []config.User{{ID: 1, Role: config.RoleAdmin}}, ExecTimeout: time.Second, DocumentThreshold: 4000}
+			telegram, err := New(cfg, bot, engine, compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			telegram.handleExec(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}, Payload: tt.payload})
+			found := false
+			for _, message := range bot.sent() {
+				found = found || strings.Contains(message.Text, tt.want)
+			}
+			if !found {
+				t.Errorf("/exec %v replied %q, want %q in it", tt.payload, bot.last().Text, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// fakeBot is an in-memory Bot recording every message and callback answer.
+type fakeBot struct {
+	mu sync.Mutex
+
+	// messages records every message sent, replied or edited, in order.
+	messages []fakeMessage
+	// responses records every callback answer, in order.
+	responses []tb.CallbackResponse
+	// handlers holds the handlers registered with Handle, keyed by endpoint.
+	handlers map[interface{}]interface{}
+	// commands holds the commands registered with SetCommands.
+	commands []tb.Command
+
+	lastID int
+}
+
+// fakeMessage is a message the handlers sent, or the new content of an edited one.
+type fakeMessage struct {
+	*tb.Message
+	// what is the text, or the *tb.Document or *tb.Photo sent.
+	what interface{}
+	// markup is the inline keyboard of the message, if any.
+	markup *tb.ReplyMarkup
+	// edited is set when the message replaced the content of an earlier one.
+	edited bool
+}
+
+var _ Bot = (*fakeBot)(nil)
+
+func newFakeBot() *fakeBot {
+	return &fakeBot{handlers: map[interface{}]interface{}{}}
+}
+
+func (f *fakeBot) Send(to tb.Recipient, what interface{}, options ...interface{}) (*tb.Message, error) {
+	chatID, err := strconv.ParseInt(to.Recipient(), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return f.record(&tb.Chat{ID: chatID}, 0, what, options), nil
+}
+
+func (f *fakeBot) Reply(to *tb.Message, what interface{}, options ...interface{}) (*tb.Message, error) {
+	return f.record(to.Chat, 0, what, options), nil
+}
+
+func (f *fakeBot) Edit(message tb.Editable, what interface{}, options ...interface{}) (*tb.Message, error) {
+	messageID, chatID := message.MessageSig()
+	id, err := strconv.Atoi(messageID)
+	if err != nil {
+		return nil, err
+	}
+	return f.record(&tb.Chat{ID: chatID}, id, what, options), nil
+}
+
+func (f *fakeBot) Respond(c *tb.Callback, responses ...*tb.CallbackResponse) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	response := tb.CallbackResponse{}
+	if len(responses) > 0 {
+		response = *responses[0]
+	}
+	f.responses = append(f.responses, response)
+	return nil
+}
+
+func (f *fakeBot) Handle(endpoint interface{}, handler interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers[endpoint] = handler
+}
+
+func (f *fakeBot) SetCommands(commands []tb.Command) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.commands = commands
+	return nil
+}
+
+// Start does nothing, updates are handed to the handlers directly.
+func (f *fakeBot) Start() {}
+
+// last returns the last message recorded, or a zero fakeMessage if there is none.
+func (f *fakeBot) last() fakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.messages) == 0 {
+		return fakeMessage{Message: &tb.Message{}}
+	}
+	return f.messages[len(f.messages)-1]
+}
+
+// sent returns a copy of the messages recorded so far.
+func (f *fakeBot) sent() []fakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]fakeMessage{}, f.messages...)
+}
+
+// record adds a message to chat, editing the message with editID when it is not zero.
+func (f *fakeBot) record(chat *tb.Chat, editID int, what interface{}, options []interface{}) *tb.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	message := fakeMessage{what: what, edited: editID != 0}
+	for _, option := range options {
+		if markup, ok := option.(*tb.ReplyMarkup); ok {
+			message.markup = markup
+		}
+	}
+	id := editID
+	if id == 0 {
+		f.lastID++
+		id = f.lastID
+	}
+	message.Message = &tb.Message{ID: id, Chat: chat, Unixtime: time.Now().Unix()}
+	if text, ok := what.(string); ok {
+		message.Text = text
+	}
+	f.messages = append(f.messages, message)
+	return message.Message
+}
@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/chart"
+	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	graphWidth  = 800
+	graphHeight = 400
+	// graphPeriod is the history charted when no period is given.
+	graphPeriod = time.Hour
+)
+
+// graphMetrics are the charts /graph can draw and their captions.
+var graphMetrics = map[string]string{
+	"cpu": "CPU usage",
+	"mem": "Memory usage",
+	"net": "Network traffic, received in blue and sent in orange",
+}
+
+// handleGraph sends a chart of the resource usage history of a container. <ContainerID> [cpu|mem|net] [1h]
+func (t *Telegram) handleGraph(m *tb.Message) {
+	usage := "Usage: /graph <code>&lt;ContainerID&gt; [cpu|mem|net] [1h]</code>"
+	args := strings.Fields(m.Payload)
+	if len(args) == 0 || len(args) > 3 || !t.dckr.IsValidID(args[0]) {
+		t.reply(m, usage)
+		return
+	}
+	metric, period := "cpu", graphPeriod
+	for _, arg := range args[1:] {
+		if _, ok := graphMetrics[arg]; ok {
+			metric = arg
+		} else if duration, err := time.ParseDuration(arg); err == nil && duration > 0 {
+			period = duration
+		} else {
+			t.reply(m, usage)
+			return
+		}
+	}
+
+	if t.sampler == nil {
+		t.reply(m, "Resource usage history is disabled, see <code>TELEDOCK_STATS_INTERVAL</code>")
+		return
+	}
+	if !t.replyScope(m, args[0]) {
+		return
+	}
+	if period > t.sampler.Retention() {
+		period = t.sampler.Retention()
+	}
+
+	samples := t.sampler.History(args[0], period)
+	graph := graphChart(metric, samples)
+	if graph.Empty() {
+		t.reply(m, fmt.Sprintf("No samples yet, one is taken every %v", t.sampler.Interval()))
+		return
+	}
+
+	var image bytes.Buffer
+	if err := graph.Render(&image); err != nil {
+		log.Error().Err(err).Msg("error rendering chart")
+		t.reply(m, "Error rendering the chart")
+		return
+	}
+	caption := fmt.Sprintf("<b>%v</b>\n%v over the last %v", html.EscapeString(samples[len(samples)-1].Name), graphMetrics[metric], period)
+	t.reply(m, &tb.Photo{File: tb.FromReader(&image), Caption: caption})
+}
+
+// graphChart builds the chart of metric out of the samples of a container.
+func graphChart(metric string, samples []docker.Stats) *chart.Chart {
+	graph := &chart.Chart{Width: graphWidth, Height: graphHeight}
+	switch metric {
+	case "cpu":
+		points := make([]chart.Point, len(samples))
+		for index, sample := range samples {
+			points[index] = chart.Point{Time: sample.Time, Value: sample.CPUPercent}
+		}
+		graph.Series = []chart.Series{{Color: chart.Blue, Points: points}}
+		graph.Max = 100
+		graph.Label = func(value float64) string { return fmt.Sprintf("%.0f%%", value) }
+	case "mem":
+		points := make([]chart.Point, len(samples))
+		for index, sample := range samples {
+			points[index] = chart.Point{Time: sample.Time, Value: float64(sample.MemoryUsage)}
+		}
+		graph.Series = []chart.Series{{Color: chart.Blue, Points: points}}
+		graph.Label = func(value float64) string { return utils.FormatBytes(uint64(value)) }
+	case "net":
+		// The counters are totals since the container started, the chart shows their rate.
+		received, sent := []chart.Point{}, []chart.Point{}
+		for index := 1; index < len(samples); index++ {
+			previous, sample := samples[index-1], samples[index]
+			elapsed := sample.Time.Sub(previous.Time).Seconds()
+			if elapsed <= 0 || sample.NetRx < previous.NetRx || sample.NetTx < previous.NetTx {
+				continue
+			}
+			received = append(received, chart.Point{Time: sample.Time, Value: float64(sample.NetRx-previous.NetRx) / elapsed})
+			sent = append(sent, chart.Point{Time: sample.Time, Value: float64(sample.NetTx-previous.NetTx) / elapsed})
+		}
+		graph.Series = []chart.Series{{Color: chart.Blue, Points: received}, {Color: chart.Orange, Points: sent}}
+		graph.Label = func(value float64) string { return utils.FormatBytes(uint64(value)) + "/s" }
+	}
+	return graph
+}
@@ -1,12 +1,17 @@
 package telegram
 
 import (
+	"errors"
 	"fmt"
+	"html"
 	"strings"
-	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/audit"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
 	"github.com/mrmarble/teledock/internal/utils"
 
 	tb "gopkg.in/tucnak/telebot.v2"
@@ -14,9 +19,9 @@ import (
 
 const FormatedStr = "<code>%v</code>"
 
-// handleStart triggers when /start is sent on private.
+// handleStart triggers when /start is sent on private, or in a group chat the sender can use the bot in.
 func (t *Telegram) handleStart(m *tb.Message) {
-	if !m.Private() {
+	if !m.Private() && t.access(m.Chat, m.Sender).Role == config.RoleNone {
 		return
 	}
 
@@ -25,41 +30,43 @@ func (t *Telegram) handleStart(m *tb.Message) {
 
 // handleList triggers when the ps command is sent.
 func (t *Telegram) handleList(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
+	containers, err := t.dckr.List(types.ContainerListOptions{})
+	if err != nil {
+		t.replyError(m, err)
 		return
 	}
-	resultMsg := utils.FormatContainerList(t.dckr.List(types.ContainerListOptions{}))
+	resultMsg := utils.FormatContainerList(filterContainers(t.access(m.Chat, m.Sender), containers))
 	t.send(m.Chat, strings.Join(resultMsg, "\n\n"))
 }
 
 // handleList triggers when the psa command is sent.
 func (t *Telegram) handleListAll(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
+	containers, err := t.dckr.List(types.ContainerListOptions{All: true})
+	if err != nil {
+		t.replyError(m, err)
 		return
 	}
-	resultMsg := utils.FormatContainerList(t.dckr.List(types.ContainerListOptions{All: true}))
+	resultMsg := utils.FormatContainerList(filterContainers(t.access(m.Chat, m.Sender), containers))
 	t.send(m.Chat, strings.Join(resultMsg, "\n\n"))
 }
 
 func (t *Telegram) handleImageList(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
+	images, err := t.listImages(t.access(m.Chat, m.Sender))
+	if err != nil {
+		t.replyError(m, err)
 		return
 	}
-	resultMsg := utils.FormatImageList(t.dckr.ListImages(types.ImageListOptions{}))
+	resultMsg := utils.FormatImageList(images)
 	t.send(m.Chat, strings.Join(resultMsg, "\n\n"))
 }
 
 func (t *Telegram) handleStop(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
-		return
-	}
-
 	containerID := m.Payload
 	if containerID == "" || !t.dckr.IsValidID(containerID) {
 		t.askForContainer(m, types.ContainerListOptions{}, "stop")
-	} else {
+	} else if t.replyScope(m, containerID) && !t.requireConfirmation(m, "stop", containerID) {
 		if err := t.dckr.Stop(containerID); err != nil {
-			t.reply(m, err.Error())
+			t.replyError(m, err)
 		} else {
 			t.reply(m, "Container stopped")
 		}
@@ -67,90 +74,176 @@ func (t *Telegram) handleStop(m *tb.Message) {
 }
 
 func (t *Telegram) handleStartContainer(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
-		return
-	}
-
 	containerID := m.Payload
 	if containerID == "" || !t.dckr.IsValidID(containerID) {
 		filters := filters.NewArgs()
 		filters.Add("status", "exited")
 		t.askForContainer(m, types.ContainerListOptions{All: true, Filters: filters}, "start")
-	} else {
+	} else if t.replyScope(m, containerID) {
 		if err := t.dckr.Start(containerID); err != nil {
-			t.reply(m, err.Error())
+			t.replyError(m, err)
 		} else {
 			t.reply(m, "Container started")
 		}
 	}
 }
 
-func (t *Telegram) handleInspect(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
+func (t *Telegram) handleRestart(m *tb.Message) {
+	containerID := m.Payload
+	if containerID == "" || !t.dckr.IsValidID(containerID) {
+		t.askForContainer(m, types.ContainerListOptions{All: true}, "restart")
+	} else if t.replyScope(m, containerID) && !t.requireConfirmation(m, "restart", containerID) {
+		if err := t.dckr.Restart(containerID); err != nil {
+			t.replyError(m, err)
+		} else {
+			t.reply(m, "Container restarted")
+		}
+	}
+}
+
+func (t *Telegram) handlePause(m *tb.Message) {
+	containerID := m.Payload
+	if containerID == "" || !t.dckr.IsValidID(containerID) {
+		filters := filters.NewArgs()
+		filters.Add("status", "running")
+		t.askForContainer(m, types.ContainerListOptions{Filters: filters}, "pause")
+	} else if t.replyScope(m, containerID) && !t.requireConfirmation(m, "pause", containerID) {
+		if err := t.dckr.Pause(containerID); err != nil {
+			t.replyError(m, err)
+		} else {
+			t.reply(m, "Container paused")
+		}
+	}
+}
+
+func (t *Telegram) handleUnpause(m *tb.Message) {
+	containerID := m.Payload
+	if containerID == "" || !t.dckr.IsValidID(containerID) {
+		filters := filters.NewArgs()
+		filters.Add("status", "paused")
+		t.askForContainer(m, types.ContainerListOptions{All: true, Filters: filters}, "unpause")
+	} else if t.replyScope(m, containerID) {
+		if err := t.dckr.Unpause(containerID); err != nil {
+			t.replyError(m, err)
+		} else {
+			t.reply(m, "Container unpaused")
+		}
+	}
+}
+
+// handleKill accepts "<ContainerID> [signal]" or just "[signal]" to pick the container from a menu.
+func (t *Telegram) handleKill(m *tb.Message) {
+	payload := strings.Fields(m.Payload)
+	containerID, signal := "", ""
+	if len(payload) > 0 && t.dckr.IsValidID(payload[0]) {
+		containerID, payload = payload[0], payload[1:]
+	}
+	args := []string{}
+	if len(payload) > 0 {
+		signal = strings.ToUpper(payload[0])
+		args = append(args, signal)
+	}
+
+	if containerID == "" {
+		filters := filters.NewArgs()
+		filters.Add("status", "running")
+		t.askForContainer(m, types.ContainerListOptions{Filters: filters}, "kill", args...)
+	} else if t.replyScope(m, containerID) && !t.requireConfirmation(m, "kill", containerID, args...) {
+		if err := t.dckr.Kill(containerID, signal); err != nil {
+			t.replyError(m, err)
+		} else {
+			t.reply(m, "Container killed")
+		}
+	}
+}
+
+func (t *Telegram) handleExec(m *tb.Message) {
+	args, err := utils.SplitArgs(m.Payload)
+	if err != nil {
+		t.reply(m, html.EscapeString(err.Error()))
+		return
+	}
+	if len(args) < 2 || !t.dckr.IsValidID(args[0]) {
+		t.reply(m, "Usage: /exec <code>&lt;ContainerID&gt; &lt;command&gt;</code>")
+		return
+	}
+	if !t.replyScope(m, args[0]) {
+		return
+	}
+
+	result, err := t.dckr.Exec(args[0], args[1:], t.cfg.ExecTimeout)
+	if errors.Is(err, docker.ErrTimeout) {
+		t.auditError(messageKey(m), err)
+		t.reply(m, fmt.Sprintf("%v The command took longer than %v and may still be running inside the container", emoji.HourglassDone, t.cfg.ExecTimeout))
+		return
+	}
+	if err != nil {
+		t.replyError(m, err)
 		return
 	}
 
+	output := strings.Split(utils.FormatExecResult(result.Stdout, result.Stderr), "\n")
+	summary := fmt.Sprintf("Exit code: %v", result.ExitCode)
+	if result.Truncated {
+		summary = fmt.Sprintf("%v, the output was too long and got truncated", summary)
+	}
+	if !t.isLargeOutput(output) {
+		t.reply(m, summary)
+	}
+	t.replyOutput(m, "exec.log", summary, output)
+}
+
+func (t *Telegram) handleInspect(m *tb.Message) {
 	containerID := m.Payload
 	if containerID == "" || !t.dckr.IsValidID(containerID) {
 		t.askForContainer(m, types.ContainerListOptions{All: true}, "inspect")
 		return
 	}
+	if !t.replyScope(m, containerID) {
+		return
+	}
 	container, err := t.dckr.Inspect(containerID)
 	if err != nil {
-		t.reply(m, err.Error())
+		t.replyError(m, err)
 		return
 	}
 
 	response, err := utils.FormatStruct(container)
 	if err != nil {
-		t.reply(m, err.Error())
-	}
-	for index, chunk := range utils.ChunkString(response, 3000) {
-		if index == 0 {
-			t.reply(m, fmt.Sprintf(FormatedStr, chunk), tb.ModeHTML)
-		} else {
-			t.send(m.Chat, fmt.Sprintf(FormatedStr, chunk), tb.ModeHTML)
-		}
-		time.Sleep(100 * time.Millisecond)
+		t.replyError(m, err)
+		return
 	}
-
+	t.replyOutput(m, fmt.Sprintf("%v.json", containerID), fmt.Sprintf("Inspect of %v", containerID), strings.Split(response, "\n"))
 }
 
+// handleStacks lists the compose stacks, with buttons to browse their services.
 func (t *Telegram) handleStacks(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
+	text, menu, err := t.renderStacks(t.access(m.Chat, m.Sender), m.Sender.ID)
+	if err != nil {
+		t.replyError(m, err)
 		return
 	}
-	resultMsg := utils.FormatComposeList(t.dckr.ListCompose())
-	t.send(m.Chat, strings.Join(resultMsg, "\n\n"))
+	t.send(m.Chat, text, menu)
 }
 
+// handleLogs shows container logs.
+// <ContainerID> [tail] [--since 1h] [--until 10m] [--grep pattern] [--timestamps] [--stdout|--stderr]
 func (t *Telegram) handleLogs(m *tb.Message) {
-	if !t.isSuperAdmin(m.Sender) {
+	args, err := parseLogsArgs(m.Payload)
+	if err != nil {
+		t.reply(m, html.EscapeString(err.Error()))
 		return
 	}
-	payload := strings.Split(m.Payload, " ")
-	containerID := payload[0]
-	if containerID == "" || !t.dckr.IsValidID(containerID) {
+	if args.containerID == "" || !t.dckr.IsValidID(args.containerID) {
 		t.askForContainer(m, types.ContainerListOptions{All: true}, "logs")
-	} else {
-		tail := "10"
-		if len(payload) > 1 {
-			tail = payload[1]
-		}
-		logs, err := t.dckr.Logs(containerID, tail)
+	} else if t.replyScope(m, args.containerID) {
+		logs, err := t.dckr.Logs(args.containerID, args.options)
 
 		if err != nil {
-			t.reply(m, err.Error())
+			t.replyError(m, err)
 			return
 		}
-		for index, chunk := range logs {
-			if index == 0 {
-				t.reply(m, fmt.Sprintf(FormatedStr, chunk), tb.ModeHTML)
-			} else {
-				t.send(m.Chat, fmt.Sprintf(FormatedStr, chunk), tb.ModeHTML)
-			}
-			time.Sleep(100 * time.Millisecond)
-		}
+		t.replyOutput(m, fmt.Sprintf("%v.log", args.containerID), logsCaption(args.containerID, logs), formatLogs(logs))
 	}
 }
 
@@ -166,20 +259,55 @@ func (t *Telegram) inspectHandler(c *tb.Callback, payload string) {
 		t.callbackResponse(c, err, payload, "")
 		return
 	}
-	for index, chunk := range utils.ChunkString(response, 3000) {
-		if index == 0 {
-			t.callbackResponse(c, err, payload, fmt.Sprintf(FormatedStr, chunk))
-		} else {
-			t.send(c.Message.Chat, fmt.Sprintf(FormatedStr, chunk), tb.ModeHTML)
-		}
-		time.Sleep(250 * time.Millisecond)
-	}
+	t.callbackOutput(c, payload, fmt.Sprintf("%v.json", payload), fmt.Sprintf("Inspect of %v", payload), strings.Split(response, "\n"))
 }
 
 func (t *Telegram) handleCallback(c *tb.Callback) {
-	parts := strings.Split(c.Data, ":")
-	instruction := parts[0]
-	payload := parts[1]
+	data, err := t.verifyCallback(c)
+	if err != nil {
+		entry := newAuditEntry(c.Message.Chat, c.Sender, "callback", strings.SplitN(c.Data, ":", 2)[0])
+		entry.Result = err.Error()
+		t.recordAudit(entry)
+		t.alert(c, err.Error())
+		return
+	}
+	parts := strings.Split(data, ":")
+	if len(parts) < 2 {
+		log.Warn().Str("data", data).Msg("malformed callback data")
+		return
+	}
+
+	entry := newAuditEntry(c.Message.Chat, c.Sender, "callback", parts[0])
+	entry.Target, entry.Args = parts[1], strings.Join(parts[2:], " ")
+	t.audits.begin(callbackKey(c), entry)
+	t.runCallback(c, parts[0], parts[1], parts[2:], false)
+	t.endAudit(callbackKey(c))
+}
+
+// runCallback runs the instruction of a callback. Destructive instructions that are
+// not confirmed yet ask for a confirmation instead.
+func (t *Telegram) runCallback(c *tb.Callback, instruction string, payload string, args []string, confirmed bool) {
+	if confirmed {
+		t.audits.update(callbackKey(c), func(entry *audit.Entry) {
+			entry.Command, entry.Target, entry.Args = instruction, payload, strings.Join(args, " ")
+		})
+	}
+	if containerCallbacks[instruction] {
+		if err := t.checkScope(t.access(c.Message.Chat, c.Sender), payload); err != nil {
+			t.callbackResponse(c, err, payload, "")
+			return
+		}
+	}
+	if _, ok := composeActions[instruction]; ok {
+		if _, ok := t.findProject(t.access(c.Message.Chat, c.Sender), payload); !ok {
+			t.callbackResponse(c, nil, payload, unknownProject(payload))
+			return
+		}
+	}
+	if !confirmed && t.needsConfirmation(instruction, payload) {
+		t.callbackResponse(c, nil, payload, t.confirmationText(instruction, payload), t.makeConfirmMenu(c.Sender, instruction, payload, args...))
+		return
+	}
 
 	switch instruction {
 	case "stop":
@@ -190,10 +318,59 @@ func (t *Telegram) handleCallback(c *tb.Callback) {
 		err := t.dckr.Start(payload)
 		t.callbackResponse(c, err, payload, fmt.Sprintf("Container %v started", payload))
 
+	case "restart":
+		err := t.dckr.Restart(payload)
+		t.callbackResponse(c, err, payload, fmt.Sprintf("Container %v restarted", payload))
+
+	case "pause":
+		err := t.dckr.Pause(payload)
+		t.callbackResponse(c, err, payload, fmt.Sprintf("Container %v paused", payload))
+
+	case "unpause":
+		err := t.dckr.Unpause(payload)
+		t.callbackResponse(c, err, payload, fmt.Sprintf("Container %v unpaused", payload))
+
+	case "kill":
+		signal := ""
+		if len(args) > 0 {
+			signal = args[0]
+		}
+		err := t.dckr.Kill(payload, signal)
+		t.callbackResponse(c, err, payload, fmt.Sprintf("Container %v killed", payload))
+
+	case "norestart":
+		err := t.dckr.SetRestartPolicy(payload, "no")
+		t.callbackResponse(c, err, payload, fmt.Sprintf("Restart policy of %v disabled", payload))
+
 	case "inspect":
 		t.inspectHandler(c, payload)
 
+	case "confirm", "reject":
+		t.handleConfirmation(c, payload, instruction == "confirm")
+
+	case "create":
+		t.handleCreateCallback(c, payload, args)
+
 	case "logs":
 		t.handleLog(c, payload)
+
+	case "tail":
+		t.callbackResponse(c, nil, payload, fmt.Sprintf("Following logs of <code>%v</code>...", payload))
+		t.startFollow(c.Message, c.Sender.ID, payload)
+
+	case "untail":
+		t.handleUntailCallback(c, payload)
+
+	case "stackstart", "stackstop", "stackrestart":
+		t.handleStackCallback(c, instruction, payload)
+
+	case "composeup", "composedown", "composepull", "composeconfig":
+		t.handleComposeCallback(c, instruction, payload)
+
+	case "navl", "navs", "navc", "navg", "navi", "navr":
+		t.handleNavigatorCallback(c, instruction, payload)
+
+	case "mute", "unmute":
+		t.handleAlertCallback(c, instruction, payload, args)
 	}
 }
@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestHandleList(t *testing.T) {
+	engine := docker.NewFake().
+		AddContainer("aaa", "web", "nginx", "running", nil).
+		AddContainer("bbb", "old", "nginx", "exited", nil)
+	bot := newFakeBot()
+	telegram, err := New(&config.Config{Users: []config.User{{ID: 1, Role: config.RoleAdmin}}}, bot, engine, compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	telegram.handleList(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}})
+	text := bot.last().Text
+	if !strings.Contains(text, "web") || strings.Contains(text, "old") {
+		t.Errorf("/ps = %q, want only the running container", text)
+	}
+}
+
+func TestHandleStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		down    bool
+		want    string
+		calls   int
+	}{
+		{"stops the container", "aaa000000000", false, "Container stopped", 1},
+		{"unknown container", "ccc000000000", false, "does not exist", 0},
+		{"daemon down", "aaa000000000", true, "unreachable", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := docker.NewFake().AddContainer("aaa", "web", "nginx", "running", nil)
+			bot := newFakeBot()
+			telegram, err := New(&config.Config{Users: []config.User{{ID: 1, Role: config.RoleAdmin}}}, bot, engine, compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			engine.Down = tt.down
+			telegram.handleStop(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}, Payload: tt.payload})
+			if text := bot.last().Text; !strings.Contains(text, tt.want) {
+				t.Errorf("/stop %v = %q, want %q in it", tt.payload, text, tt.want)
+			}
+			if len(engine.Calls) != tt.calls {
+				t.Errorf("engine calls = %v, want %v", engine.Calls, tt.calls)
+			}
+		})
+	}
+}
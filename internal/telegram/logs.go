@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
+)
+
+// stderrLabel marks stderr lines when both streams are shown together.
+const stderrLabel = "[err] "
+
+// formatLogs renders log lines as text lines.
+func formatLogs(lines []docker.LogLine) []string {
+	if len(lines) == 0 {
+		return []string{"(no logs)"}
+	}
+
+	mixed := false
+	for _, line := range lines {
+		if line.Stream != lines[0].Stream {
+			mixed = true
+			break
+		}
+	}
+
+	texts := make([]string, len(lines))
+	for index, line := range lines {
+		texts[index] = utils.StripANSI(line.Text)
+		if mixed && line.Stream == docker.Stderr {
+			texts[index] = stderrLabel + texts[index]
+		}
+	}
+	return texts
+}
+
+// logsCaption summarizes logs sent as a file.
+func logsCaption(containerID string, lines []docker.LogLine) string {
+	return fmt.Sprintf("%v log lines of %v", len(lines), containerID)
+}
+
+// logsArgs are the arguments of /logs.
+type logsArgs struct {
+	containerID string
+	options     docker.LogsOptions
+}
+
+// parseLogsArgs parses "<ContainerID> [tail] [--since t] [--until t] [--grep re]
+// [--timestamps] [--stdout|--stderr]". Flags may come before or after the positional
+// arguments. The tail is taken after filtering with --grep.
+func parseLogsArgs(payload string) (*logsArgs, error) {
+	args, err := utils.SplitArgs(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	since := flags.String("since", "", "show logs since a timestamp or a duration like 1h")
+	until := flags.String("until", "", "show logs until a timestamp or a duration like 10m")
+	grep := flags.String("grep", "", "show only the lines matching a regular expression")
+	timestamps := flags.Bool("timestamps", false, "show timestamps")
+	stdout := flags.Bool("stdout", false, "show only stdout")
+	stderr := flags.Bool("stderr", false, "show only stderr")
+
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	parsed := &logsArgs{options: docker.LogsOptions{
+		Tail:       "10",
+		Stdout:     *stdout,
+		Stderr:     *stderr,
+		Since:      *since,
+		Until:      *until,
+		Timestamps: *timestamps,
+	}}
+	if len(positional) > 0 {
+		parsed.containerID = positional[0]
+	}
+	// A time window or a filter already narrows the logs down, look at all of them.
+	if *since != "" || *grep != "" {
+		parsed.options.Tail = "all"
+	}
+	if len(positional) > 1 {
+		if positional[1] != "all" && !utils.IsNumber(positional[1]) {
+			return nil, fmt.Errorf("invalid tail %q, expected a number or all", positional[1])
+		}
+		parsed.options.Tail = positional[1]
+	}
+	for _, value := range []string{*since, *until} {
+		if value != "" && !isTimeArg(value) {
+			return nil, fmt.Errorf("invalid time %q, expected a duration like 1h or a RFC3339 timestamp", value)
+		}
+	}
+	if *grep != "" {
+		if parsed.options.Grep, err = regexp.Compile(*grep); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	return parsed, nil
+}
+
+// isTimeArg reports whether value is a duration, a RFC3339 timestamp or a unix timestamp.
+func isTimeArg(value string) bool {
+	if _, err := time.ParseDuration(value); err == nil {
+		return true
+	}
+	if _, err := time.Parse(time.RFC3339, value); err == nil {
+		return true
+	}
+	return utils.IsNumber(value)
+}
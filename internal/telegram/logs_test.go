@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/docker"
+)
+
+func TestFormatLogs(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []docker.LogLine
+		want  []string
+	}{
+		{"no logs", nil, []string{"(no logs)"}},
+		{"stdout only", []docker.LogLine{{Stream: docker.Stdout, Text: "a"}, {Stream: docker.Stdout, Text: "b"}}, []string{"a", "b"}},
+		{"stderr only", []docker.LogLine{{Stream: docker.Stderr, Text: "oops"}}, []string{"oops"}},
+		{"mixed", []docker.LogLine{{Stream: docker.Stdout, Text: "a"}, {Stream: docker.Stderr, Text: "oops"}}, []string{"a", "[err] oops"}},
+		{"colors", []docker.LogLine{{Stream: docker.Stdout, Text: "\x1b[31mred\x1b[0m"}}, []string{"red"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLogs(tt.lines); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("formatLogs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLogsArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		containerID string
+		options     docker.LogsOptions
+		grep        string
+		wantErr     bool
+	}{
+		{name: "empty", payload: "", options: docker.LogsOptions{Tail: "10"}},
+		{name: "container", payload: "abc", containerID: "abc", options: docker.LogsOptions{Tail: "10"}},
+		{name: "tail", payload: "abc 50", containerID: "abc", options: docker.LogsOptions{Tail: "50"}},
+		{name: "all", payload: "abc all", containerID: "abc", options: docker.LogsOptions{Tail: "all"}},
+		{name: "since reads everything", payload: "abc --since 1h", containerID: "abc", options: docker.LogsOptions{Tail: "all", Since: "1h"}},
+		{name: "grep reads everything", payload: "abc --grep error", containerID: "abc", options: docker.LogsOptions{Tail: "all"}, grep: "error"},
+		{name: "grep with tail", payload: "abc 5 --grep 'a b'", containerID: "abc", options: docker.LogsOptions{Tail: "5"}, grep: "a b"},
+		{name: "flags first", payload: "--stderr --timestamps abc 20", containerID: "abc", options: docker.LogsOptions{Tail: "20", Stderr: true, Timestamps: true}},
+		{name: "until timestamp", payload: "abc --until 2022-01-02T15:04:05Z", containerID: "abc", options: docker.LogsOptions{Tail: "10", Until: "2022-01-02T15:04:05Z"}},
+		{name: "bad tail", payload: "abc many", wantErr: true},
+		{name: "bad time", payload: "abc --since yesterday", wantErr: true},
+		{name: "bad pattern", payload: "abc --grep '('", wantErr: true},
+		{name: "unknown flag", payload: "abc --follow", wantErr: true},
+		{name: "unterminated quote", payload: "abc --grep 'oops", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLogsArgs(tt.payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseLogsArgs(%q) succeeded, want an error", tt.payload)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLogsArgs(%q) error = %v", tt.payload, err)
+			}
+			grep := ""
+			if got.options.Grep != nil {
+				grep = got.options.Grep.String()
+			}
+			got.options.Grep = nil
+			if got.containerID != tt.containerID || !reflect.DeepEqual(got.options, tt.options) || grep != tt.grep {
+				t.Errorf("parseLogsArgs(%q) = %q %+v grep %q, want %q %+v grep %q", tt.payload, got.containerID, got.options, grep, tt.containerID, tt.options, tt.grep)
+			}
+		})
+	}
+}
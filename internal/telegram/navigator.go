@@ -0,0 +1,216 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/constants"
+	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// navigatorColumns is how many stack buttons each row of the stack list has.
+	navigatorColumns = 2
+	// navigatorOutputSize is how much output fits in the navigator message, longer
+	// output is sent as a file.
+	navigatorOutputSize = 3000
+)
+
+// The navigator browses stacks, then the services of a stack, then the actions of a
+// container, editing the same message on every step:
+//
+//	navl:all         the list of stacks
+//	navs:<stack>     the services of a stack
+//	navc:<container> the actions of a container
+//	navg:<container> the last logs of a container
+//	navi:<container> the inspect of a container
+//	navr:<container> restarts a container
+
+// navigatorActions maps the container actions of the navigator to the command
+// they stand for, they are checked and confirmed like it.
+var navigatorActions = map[string]string{
+	"navg": "logs",
+	"navi": "inspect",
+	"navr": "restart",
+}
+
+// handleNavigatorCallback moves the navigator to the step of instruction. Errors
+// are shown in the navigator with a way back to the stacks.
+func (t *Telegram) handleNavigatorCallback(c *tb.Callback, instruction string, payload string) {
+	access := t.access(c.Message.Chat, c.Sender)
+	var (
+		text string
+		menu *tb.ReplyMarkup
+		err  error
+	)
+	switch instruction {
+	case "navl":
+		text, menu, err = t.renderStacks(access, c.Sender.ID)
+	case "navs":
+		text, menu, err = t.renderStack(access, c.Sender.ID, payload)
+	case "navc":
+		text, menu, err = t.renderStackContainer(c.Sender.ID, payload, "")
+	case "navg", "navi":
+		var sent bool
+		if sent, text, menu, err = t.renderStackOutput(c, instruction, payload); sent {
+			return
+		}
+	case "navr":
+		if err = t.dckr.Restart(payload); err == nil {
+			text, menu, err = t.renderStackContainer(c.Sender.ID, payload, fmt.Sprintf("%v Container restarted", emoji.CheckMarkButton))
+		}
+	}
+	if err != nil {
+		t.auditError(callbackKey(c), err)
+		text, menu = formatError(err), t.newMenu(c.Sender.ID, [][]tb.InlineButton{{{Text: "« Stacks", Data: "navl:all"}}})
+	}
+	t.callbackResponse(c, nil, payload, text, menu)
+}
+
+// renderStacks lists the stacks within reach of access, with a button to browse each one.
+func (t *Telegram) renderStacks(access config.Access, owner int64) (string, *tb.ReplyMarkup, error) {
+	stacks, err := t.dckr.ListCompose()
+	if err != nil {
+		return "", nil, err
+	}
+	stacks = filterStacks(access, stacks)
+
+	names := make([]string, 0, len(stacks))
+	for name := range stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := [][]tb.InlineButton{}
+	for _, name := range names {
+		button := tb.InlineButton{Text: name, Data: fmt.Sprintf("navs:%v", name)}
+		if len(rows) == 0 || len(rows[len(rows)-1]) == navigatorColumns {
+			rows = append(rows, []tb.InlineButton{})
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], button)
+	}
+	return strings.Join(utils.FormatComposeList(stacks), "\n\n"), t.newMenu(owner, rows), nil
+}
+
+// renderStack shows the services of a stack, with a button for the container of each one.
+func (t *Telegram) renderStack(access config.Access, owner int64, name string) (string, *tb.ReplyMarkup, error) {
+	containers, err := t.stackContainers(access, name)
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Names[0] < containers[j].Names[0]
+	})
+
+	services := map[string]int{}
+	for _, container := range containers {
+		services[container.Labels[constants.ComposeServiceLabel]]++
+	}
+	rows := [][]tb.InlineButton{}
+	for _, container := range containers {
+		// Services with replicas show the name of each container instead.
+		text := container.Labels[constants.ComposeServiceLabel]
+		if services[text] > 1 || text == "" {
+			text = container.Names[0][1:]
+		}
+		rows = append(rows, []tb.InlineButton{{Text: fmt.Sprintf("%v %v", utils.StateIcon(container.State), text), Data: fmt.Sprintf("navc:%v", shortID(container.ID))}})
+	}
+	rows = append(rows, []tb.InlineButton{{Text: "« Stacks", Data: "navl:all"}})
+	return strings.Join(utils.FormatStack(name, containers), "\n\n"), t.newMenu(owner, rows), nil
+}
+
+// renderStackContainer shows a container of a stack with its actions, after notice
+// if any. The scope of the container is checked by runCallback.
+func (t *Telegram) renderStackContainer(owner int64, containerID string, notice string) (string, *tb.ReplyMarkup, error) {
+	container, err := t.dckr.Inspect(containerID)
+	if err != nil {
+		return "", nil, err
+	}
+	labels, image := containerConfig(container)
+	status := ""
+	if container.State != nil {
+		status = container.State.Status
+	}
+
+	lines := []string{
+		fmt.Sprintf("<b>%v</b>", html.EscapeString(strings.TrimPrefix(container.Name, "/"))),
+		fmt.Sprintf(constants.FormatedStrPadded, "ID:", container.ID[:12]),
+		fmt.Sprintf(constants.FormatedStrPadded, "STATUS:", status),
+		fmt.Sprintf(constants.FormatedStrPadded, "IMAGE:", html.EscapeString(image)),
+		fmt.Sprintf(constants.FormatedStrPadded, "STACK:", html.EscapeString(labels[constants.ComposeLabel])),
+		fmt.Sprintf(constants.FormatedStrPadded, "SERVICE:", html.EscapeString(labels[constants.ComposeServiceLabel])),
+	}
+	if notice != "" {
+		lines = append([]string{notice, ""}, lines...)
+	}
+	return strings.Join(lines, "\n"), t.makeNavigatorMenu(owner, containerID, labels[constants.ComposeLabel]), nil
+}
+
+// renderStackOutput shows the logs or the inspect of a container in the navigator.
+// Output too long for the message is sent as a file instead, leaving the navigator
+// as it is, and sent is set.
+func (t *Telegram) renderStackOutput(c *tb.Callback, instruction string, containerID string) (bool, string, *tb.ReplyMarkup, error) {
+	container, err := t.dckr.Inspect(containerID)
+	if err != nil {
+		return false, "", nil, err
+	}
+	name := strings.TrimPrefix(container.Name, "/")
+
+	var lines []string
+	fileName, caption := fmt.Sprintf("%v.json", containerID), fmt.Sprintf("Inspect of %v", name)
+	if instruction == "navg" {
+		logs, err := t.dckr.Logs(containerID, docker.LogsOptions{Tail: "10"})
+		if err != nil {
+			return false, "", nil, err
+		}
+		lines, fileName, caption = formatLogs(logs), fmt.Sprintf("%v.log", containerID), logsCaption(name, logs)
+	} else {
+		response, err := utils.FormatStruct(container)
+		if err != nil {
+			return false, "", nil, err
+		}
+		lines = strings.Split(response, "\n")
+	}
+
+	if chunks := utils.ChunkLines(lines, navigatorOutputSize); len(chunks) > 1 {
+		if err := t.bot.Respond(c, &tb.CallbackResponse{Text: "Sent as a file"}); err != nil {
+			log.Error().Err(err).Msg("error replying to callback")
+		}
+		t.send(c.Message.Chat, t.makeDocument(fileName, caption, lines))
+		return true, "", nil, nil
+	}
+	labels, _ := containerConfig(container)
+	text := fmt.Sprintf("<b>%v</b>\n<pre>%v</pre>", html.EscapeString(caption), html.EscapeString(strings.Join(lines, "\n")))
+	return false, text, t.makeNavigatorMenu(c.Sender.ID, containerID, labels[constants.ComposeLabel]), nil
+}
+
+// makeNavigatorMenu builds the actions of a container in the navigator, with a
+// button back to its stack.
+func (t *Telegram) makeNavigatorMenu(owner int64, containerID string, stack string) *tb.ReplyMarkup {
+	back := tb.InlineButton{Text: "« Stacks", Data: "navl:all"}
+	if stack != "" {
+		back = tb.InlineButton{Text: fmt.Sprintf("« %v", stack), Data: fmt.Sprintf("navs:%v", stack)}
+	}
+	return t.newMenu(owner, [][]tb.InlineButton{
+		{
+			{Text: "Logs", Data: fmt.Sprintf("navg:%v", containerID)},
+			{Text: "Inspect", Data: fmt.Sprintf("navi:%v", containerID)},
+			{Text: "Restart", Data: fmt.Sprintf("navr:%v", containerID)},
+		},
+		{back},
+	})
+}
+
+// containerConfig returns the labels and image of an inspected container.
+func containerConfig(container *types.ContainerJSON) (map[string]string, string) {
+	if container.Config == nil {
+		return map[string]string{}, ""
+	}
+	return container.Config.Labels, container.Config.Image
+}
@@ -0,0 +1,121 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// press presses the first button of message whose label holds text as user, and
+// returns the last message after it.
+func press(t *testing.T, telegram *Telegram, bot *fakeBot, user int64, message fakeMessage, text string) fakeMessage {
+	t.Helper()
+	if message.markup != nil {
+		for _, row := range message.markup.InlineKeyboard {
+			for _, button := range row {
+				if strings.Contains(button.Text, text) {
+					telegram.handleCallback(&tb.Callback{ID: "1", Sender: &tb.User{ID: user}, Message: message.Message, Data: button.Data})
+					return bot.last()
+				}
+			}
+		}
+	}
+	t.Fatalf("no %q button in %q", text, message.Text)
+	return fakeMessage{}
+}
+
+// stackContainer returns the full ID of the container of service in stack.
+func stackContainer(t *testing.T, engine *docker.Fake, stack, service string) string {
+	t.Helper()
+	for _, container := range engine.Containers {
+		if container.Names[0] == fmt.Sprintf("/%v_%v_1", stack, service) {
+			return container.ID
+		}
+	}
+	t.Fatalf("no container for %v in %v", service, stack)
+	return ""
+}
+
+func TestNavigatorLogs(t *testing.T) {
+	engine := docker.NewFake().AddStack("shop", "web", "db")
+	engine.ContainerLogs[stackContainer(t, engine, "shop", "web")] = []docker.LogLine{{Stream: docker.Stdout, Text: "listening on :80"}}
+	bot := newFakeBot()
+	telegram, err := New(composeConfig(), bot, engine, compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	telegram.handleStacks(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}})
+	stacks := bot.last()
+	container := press(t, telegram, bot, 1, press(t, telegram, bot, 1, stacks, "shop"), "web")
+	logs := press(t, telegram, bot, 1, container, "Logs")
+
+	if !logs.edited || logs.ID != stacks.ID {
+		t.Errorf("logs were sent as message %v, want an edit of %v", logs.ID, stacks.ID)
+	}
+	if !strings.Contains(logs.Text, "listening on :80") {
+		t.Errorf("logs = %q, want the log line in it", logs.Text)
+	}
+	back := press(t, telegram, bot, 1, logs, "« shop")
+	if !strings.Contains(back.Text, "web") || !strings.Contains(back.Text, "db") {
+		t.Errorf("back = %q, want the services of shop", back.Text)
+	}
+}
+
+func TestNavigatorLongLogsAsFile(t *testing.T) {
+	engine := docker.NewFake().AddStack("shop", "web")
+	lines := []docker.LogLine{}
+	for index := 0; index < 10; index++ {
+		lines = append(lines, docker.LogLine{Stream: docker.Stdout, Text: strings.Repeat("x", 500)})
+	}
+	engine.ContainerLogs[stackContainer(t, engine, "shop", "web")] = lines
+	bot := newFakeBot()
+	telegram, err := New(composeConfig(), bot, engine, compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	telegram.handleStacks(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}})
+	container := press(t, telegram, bot, 1, press(t, telegram, bot, 1, bot.last(), "shop"), "web")
+	sent := len(bot.sent())
+	file := press(t, telegram, bot, 1, container, "Logs")
+
+	if len(bot.sent()) != sent+1 {
+		t.Fatalf("sent %v messages, want only the file", len(bot.sent())-sent)
+	}
+	if _, ok := file.what.(*tb.Document); !ok {
+		t.Errorf("sent %T, want a document", file.what)
+	}
+	if response := bot.responses[len(bot.responses)-1]; response.Text != "Sent as a file" {
+		t.Errorf("callback answer = %q, want %q", response.Text, "Sent as a file")
+	}
+}
+
+func TestNavigatorScope(t *testing.T) {
+	engine := docker.NewFake().AddStack("shop", "web").AddStack("blog", "web")
+	bot := newFakeBot()
+	telegram, err := New(composeConfig(), bot, engine, compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	telegram.handleStacks(&tb.Message{Chat: &tb.Chat{ID: 2}, Sender: &tb.User{ID: 2}})
+	stacks := bot.last()
+	if strings.Contains(stacks.Text, "blog") {
+		t.Errorf("stacks = %q, want only shop", stacks.Text)
+	}
+
+	data, err := telegram.signCallback("navc:"+stackContainer(t, engine, "blog", "web")[:12], 2, time.Now().Add(time.Hour).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram.handleCallback(&tb.Callback{ID: "1", Sender: &tb.User{ID: 2}, Message: stacks.Message, Data: data})
+	if denied := bot.responses[len(bot.responses)-1]; denied.Text == "" {
+		t.Error("operator browsed a container out of scope")
+	}
+}
@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// eventsRetryInterval is how long to wait before subscribing again to the
+	// events of an unreachable daemon.
+	eventsRetryInterval = 10 * time.Second
+	// killedWindow is how long after a kill a container dying is expected and not notified.
+	killedWindow = 30 * time.Second
+)
+
+// stopSignals are the signals, SIGKILL and SIGTERM, a container is stopped with.
+var stopSignals = map[string]bool{"9": true, "15": true}
+
+// eventEmojis prefixes the notification of each event.
+var eventEmojis = map[string]emoji.Emoji{
+	docker.EventStart:     emoji.GreenCircle,
+	docker.EventStop:      emoji.StopButton,
+	docker.EventRestart:   emoji.CounterclockwiseArrowsButton,
+	docker.EventDie:       emoji.RedCircle,
+	docker.EventKill:      emoji.Skull,
+	docker.EventOOM:       emoji.ExplodingHead,
+	docker.EventHealthy:   emoji.CheckMarkButton,
+	docker.EventUnhealthy: emoji.FaceWithThermometer,
+	"pause":               emoji.PauseButton,
+	"unpause":             emoji.PlayButton,
+}
+
+// watchEvents sends the container events to the notification chats, subscribing
+// again whenever the daemon goes away.
+func (t *Telegram) watchEvents() {
+	killed := map[string]time.Time{}
+	crashes := newCrashDetector(t.cfg.CrashLoopRestarts, t.cfg.CrashLoopWindow)
+	for {
+		events, err := t.dckr.Events(context.Background())
+		if err != nil {
+			time.Sleep(eventsRetryInterval)
+			continue
+		}
+		log.Info().Msg("watching container events")
+
+		for event := range events {
+			// Stopping or killing a container makes it die, only unexpected deaths are notified.
+			// Other signals, like a SIGHUP to reload, don't hide a crash that follows.
+			if event.Action == docker.EventKill && stopSignals[event.Signal] {
+				forgetKills(killed, event.Time)
+				killed[event.ContainerID] = event.Time
+			}
+			if event.Action == docker.EventDie {
+				killedAt, ok := killed[event.ContainerID]
+				delete(killed, event.ContainerID)
+				if ok && event.Time.Sub(killedAt) < killedWindow {
+					continue
+				}
+			}
+
+			looping, deaths := crashes.observe(event)
+			if deaths != nil {
+				t.notifyCrashLoop(event, deaths)
+			}
+			t.notifyChats(event.Action, event, formatEvent(event), t.makeEventMenu(event), looping)
+		}
+
+		log.Warn().Msg("container events stream ended")
+		time.Sleep(eventsRetryInterval)
+	}
+}
+
+// notifyChats sends text to the chats of the notifications that want action of
+// the container of event. When looping the event is part of a crash loop and the
+// chats getting crash loop alerts for the container are skipped.
+func (t *Telegram) notifyChats(action string, event docker.Event, text string, menu *tb.ReplyMarkup, looping bool) {
+	sent := map[int64]bool{}
+	for _, notification := range t.cfg.Notifications {
+		if looping && t.cfg.Notifies(notification, "crashloop", event.Name, event.Labels) {
+			for _, chatID := range notification.Chats {
+				sent[chatID] = true
+			}
+		}
+	}
+	for _, notification := range t.cfg.Notifications {
+		if !t.cfg.Notifies(notification, action, event.Name, event.Labels) {
+			continue
+		}
+		for _, chatID := range notification.Chats {
+			if sent[chatID] {
+				continue
+			}
+			sent[chatID] = true
+			// An unreachable chat must not hold up the events that follow.
+			t.outbox.push(chatID, text, menu)
+		}
+	}
+}
+
+// forgetKills drops the kills that happened longer than killedWindow before now,
+// of containers that never died.
+func forgetKills(killed map[string]time.Time, now time.Time) {
+	for containerID, killedAt := range killed {
+		if now.Sub(killedAt) >= killedWindow {
+			delete(killed, containerID)
+		}
+	}
+}
+
+// makeEventMenu builds the quick actions of a notification, usable by anyone allowed in the chat.
+func (t *Telegram) makeEventMenu(event docker.Event) *tb.ReplyMarkup {
+	containerID := shortID(event.ContainerID)
+	return t.newMenu(0, [][]tb.InlineButton{{
+		{Text: "Restart", Data: fmt.Sprintf("restart:%v", containerID)},
+		{Text: "Logs", Data: fmt.Sprintf("logs:%v", containerID)},
+	}})
+}
+
+func formatEvent(event docker.Event) string {
+	icon, ok := eventEmojis[event.Action]
+	if !ok {
+		icon = emoji.Warning
+	}
+	text := fmt.Sprintf("%v <code>%v</code>: %v", icon, html.EscapeString(event.Name), event.Action)
+	if event.Action == docker.EventDie && event.ExitCode != "" {
+		text = fmt.Sprintf("%v with exit code %v", text, html.EscapeString(event.ExitCode))
+	}
+	return fmt.Sprintf("%v\n<i>%v at %v</i>", text, html.EscapeString(event.Image), event.Time.Format("2006-01-02 15:04:05"))
+}
+
+// shortID abbreviates a container ID to fit in callback data.
+func shortID(containerID string) string {
+	if len(containerID) > 10 {
+		return containerID[:10]
+	}
+	return containerID
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+)
+
+func TestNotifyChats(t *testing.T) {
+	bot := newFakeBot()
+	cfg := &config.Config{Notifications: []config.Notification{
+		{Chats: []int64{1, 2}, Events: []string{docker.EventDie}},
+		{Chats: []int64{2, 3}, Events: []string{docker.EventStart}},
+	}}
+	telegram, err := New(cfg, bot, docker.NewFake(), compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := docker.Event{ContainerID: "aaa000000000", Name: "web", Action: docker.EventDie}
+	for index := 0; index < 10; index++ {
+		telegram.notifyChats(event.Action, event, fmt.Sprint(index), nil, false)
+	}
+
+	// Chat 2 is in both notifications but gets every event once, chat 3 doesn't want deaths.
+	received := map[int64][]string{}
+	for _, message := range waitSent(t, bot, 20) {
+		received[message.Chat.ID] = append(received[message.Chat.ID], message.what.(string))
+	}
+	if len(received) != 2 || len(received[1]) != 10 || len(received[2]) != 10 {
+		t.Fatalf("notified %v", received)
+	}
+	for chatID, texts := range received {
+		for index, text := range texts {
+			if text != fmt.Sprint(index) {
+				t.Fatalf("chat %v got %v in position %v", chatID, text, index)
+			}
+		}
+	}
+}
+
+func TestNotifyChatsLooping(t *testing.T) {
+	bot := newFakeBot()
+	cfg := &config.Config{Notifications: []config.Notification{
+		{Chats: []int64{1}, Events: []string{docker.EventDie, "crashloop"}},
+		{Chats: []int64{2}, Events: []string{docker.EventDie}},
+	}}
+	telegram, err := New(cfg, bot, docker.NewFake(), compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chat 1 hears about the loop from its alert, chat 2 never will and gets every death.
+	event := docker.Event{ContainerID: "aaa000000000", Name: "web", Action: docker.EventDie}
+	telegram.notifyChats(event.Action, event, "looping", nil, true)
+	telegram.notifyChats(event.Action, event, "first", nil, false)
+
+	sent := waitSent(t, bot, 3)
+	if len(sent) != 3 {
+		t.Fatalf("sent %v messages, want 3", len(sent))
+	}
+	received := map[int64][]string{}
+	for _, message := range sent {
+		received[message.Chat.ID] = append(received[message.Chat.ID], message.what.(string))
+	}
+	if fmt.Sprint(received[1]) != "[first]" || fmt.Sprint(received[2]) != "[looping first]" {
+		t.Errorf("notified %v", received)
+	}
+}
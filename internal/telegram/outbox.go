@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"sync"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// outboxSize is how many messages can wait to be sent to a chat, newer ones are
+// dropped when it's full.
+const outboxSize = 100
+
+type outgoing struct {
+	what    interface{}
+	options []interface{}
+}
+
+// outbox sends messages in the background in the order they were pushed, one
+// chat at a time, so an unreachable chat only holds up its own messages.
+type outbox struct {
+	mu     sync.Mutex
+	queues map[int64]chan outgoing
+	send   func(to tb.Recipient, what interface{}, options ...interface{}) *tb.Message
+}
+
+func newOutbox(send func(to tb.Recipient, what interface{}, options ...interface{}) *tb.Message) *outbox {
+	return &outbox{queues: map[int64]chan outgoing{}, send: send}
+}
+
+// push queues a message to chatID, starting the sender of the chat on its first message.
+func (o *outbox) push(chatID int64, what interface{}, options ...interface{}) {
+	o.mu.Lock()
+	queue, ok := o.queues[chatID]
+	if !ok {
+		queue = make(chan outgoing, outboxSize)
+		o.queues[chatID] = queue
+		go o.drain(chatID, queue)
+	}
+	o.mu.Unlock()
+
+	select {
+	case queue <- outgoing{what: what, options: options}:
+	default:
+		log.Warn().Int64("chat", chatID).Msg("too many messages waiting, dropping one")
+	}
+}
+
+func (o *outbox) drain(chatID int64, queue chan outgoing) {
+	for message := range queue {
+		o.send(tb.ChatID(chatID), message.what, message.options...)
+	}
+}
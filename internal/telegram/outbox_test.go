@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// waitSent waits for bot to have sent count messages.
+func waitSent(t *testing.T, bot *fakeBot, count int) []fakeMessage {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sent := bot.sent()
+		if len(sent) >= count {
+			return sent
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sent %v messages, want %v", len(sent), count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOutboxKeepsOrder(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent = map[int64][]string{}
+	)
+	box := newOutbox(func(to tb.Recipient, what interface{}, options ...interface{}) *tb.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		chatID, _ := strconv.ParseInt(to.Recipient(), 10, 64)
+		sent[chatID] = append(sent[chatID], what.(string))
+		return nil
+	})
+
+	for index := 0; index < 50; index++ {
+		box.push(1, strconv.Itoa(index))
+		box.push(2, strconv.Itoa(index))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		done := len(sent[1]) == 50 && len(sent[2]) == 50
+		mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("messages not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for chatID, messages := range sent {
+		for index, message := range messages {
+			if message != strconv.Itoa(index) {
+				t.Fatalf("chat %v got message %v in position %v", chatID, message, index)
+			}
+		}
+	}
+}
+
+func TestShellInputAudit(t *testing.T) {
+	bot := newFakeBot()
+	cfg := &config.Config{AuditFile: filepath.Join(t.TempDir(), "audit", "audit.jsonl"), AuditChat: 5}
+	telegram, err := New(cfg, bot, docker.NewFake(), compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := &bytes.Buffer{}
+	chat := &tb.Chat{ID: 1}
+	telegram.shells.add(&shellSession{owner: 1, chat: chat, containerID: "aaa000000000", exec: &docker.ShellSession{Stdin: stdin}, done: make(chan struct{})})
+	if !telegram.handleShellInput(&tb.Message{Chat: chat, Sender: &tb.User{ID: 1}, Text: "mysql -psecret"}) {
+		t.Fatal("input not consumed by the shell")
+	}
+	if stdin.String() != "mysql -psecret\n" {
+		t.Errorf("shell got %q", stdin.String())
+	}
+
+	entries, err := telegram.audit.Recent(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Args != "14 characters" {
+		t.Fatalf("recorded %+v, want the size of the input", entries)
+	}
+	forwarded := waitSent(t, bot, 1)[0]
+	if text := forwarded.what.(string); strings.Contains(text, "secret") || forwarded.Chat.ID != 5 {
+		t.Errorf("forwarded %q to %v", text, forwarded.Chat.ID)
+	}
+}
@@ -0,0 +1,105 @@
+package telegram
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// access returns what user can do in chat. Messages without sender, like channel posts, can't do anything.
+func (t *Telegram) access(chat *tb.Chat, user *tb.User) config.Access {
+	if user == nil {
+		return t.cfg.Access(chat.ID, 0)
+	}
+	return t.cfg.Access(chat.ID, user.ID)
+}
+
+// filterContainers drops the containers out of the reach of access.
+func filterContainers(access config.Access, containers []types.Container) []types.Container {
+	if access.Unrestricted() {
+		return containers
+	}
+	visible := []types.Container{}
+	for _, container := range containers {
+		name := ""
+		if len(container.Names) > 0 {
+			name = container.Names[0]
+		}
+		if access.Allows(name, container.Labels) {
+			visible = append(visible, container)
+		}
+	}
+	return visible
+}
+
+// filterStacks drops the containers out of the reach of access, and the stacks left empty.
+func filterStacks(access config.Access, stacks map[string][]types.Container) map[string][]types.Container {
+	visible := map[string][]types.Container{}
+	for stack, containers := range stacks {
+		if containers = filterContainers(access, containers); len(containers) > 0 {
+			visible[stack] = containers
+		}
+	}
+	return visible
+}
+
+// listImages returns the images, only the ones used by containers within reach
+// for scoped accesses.
+func (t *Telegram) listImages(access config.Access) ([]types.ImageSummary, error) {
+	images, err := t.dckr.ListImages(types.ImageListOptions{})
+	if err != nil || access.Unrestricted() {
+		return images, err
+	}
+	containers, err := t.dckr.List(types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	// Containers name their image by ID, and by the tag they were created from.
+	used := map[string]bool{}
+	for _, container := range filterContainers(access, containers) {
+		used[container.ImageID], used[container.Image] = true, true
+	}
+	visible := []types.ImageSummary{}
+	for _, image := range images {
+		inUse := used[image.ID]
+		for _, tag := range image.RepoTags {
+			inUse = inUse || used[tag]
+		}
+		if inUse {
+			visible = append(visible, image)
+		}
+	}
+	return visible, nil
+}
+
+// checkScope returns an error when containerID is out of the reach of access.
+// Containers out of reach are reported as not found so their existence isn't leaked.
+func (t *Telegram) checkScope(access config.Access, containerID string) error {
+	if access.Unrestricted() {
+		return nil
+	}
+	container, err := t.dckr.Inspect(containerID)
+	if err != nil {
+		return err
+	}
+	labels := map[string]string{}
+	if container.Config != nil {
+		labels = container.Config.Labels
+	}
+	if !access.Allows(container.Name, labels) {
+		log.Warn().Str("containerID", containerID).Msg("container out of scope")
+		return &docker.Error{Op: "access", ContainerID: containerID, Kind: docker.ErrNotFound}
+	}
+	return nil
+}
+
+// replyScope replies with an error when containerID is out of the reach of the sender.
+// It reports whether the container can be used.
+func (t *Telegram) replyScope(m *tb.Message, containerID string) bool {
+	if err := t.checkScope(t.access(m.Chat, m.Sender), containerID); err != nil {
+		t.replyError(m, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// scopedConfig has an unscoped admin, user 1, and an admin limited to the shop-* containers, user 2.
+func scopedConfig() *config.Config {
+	return &config.Config{
+		Users: []config.User{
+			{ID: 1, Role: config.RoleAdmin},
+			{ID: 2, Role: config.RoleAdmin, Scopes: []string{"shop"}},
+		},
+		Scopes: map[string]config.Scope{"shop": {Names: []string{"shop-*"}}},
+	}
+}
+
+func TestHandleImageListScope(t *testing.T) {
+	tests := []struct {
+		user int64
+		want []string
+		not  []string
+	}{
+		{1, []string{"shop:1", "blog:1", "unused:1"}, nil},
+		{2, []string{"shop:1"}, []string{"blog:1", "unused:1"}},
+	}
+	for _, tt := range tests {
+		engine := docker.NewFake().
+			AddImage("aaa", "shop:1").AddImage("bbb", "blog:1").AddImage("ccc", "unused:1").
+			AddContainer("aaa", "shop-web", "shop:1", "running", nil).
+			AddContainer("bbb", "blog-web", "blog:1", "exited", nil)
+		bot := newFakeBot()
+		telegram, err := New(scopedConfig(), bot, engine, compose.NewFake())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		telegram.handleImageList(&tb.Message{Chat: &tb.Chat{ID: tt.user}, Sender: &tb.User{ID: tt.user}})
+		text := bot.last().Text
+		for _, image := range tt.want {
+			if !strings.Contains(text, image) {
+				t.Errorf("user %v /images = %q, want %v in it", tt.user, text, image)
+			}
+		}
+		for _, image := range tt.not {
+			if strings.Contains(text, image) {
+				t.Errorf("user %v /images = %q, want no %v in it", tt.user, text, image)
+			}
+		}
+	}
+}
+
+func TestReplyScope(t *testing.T) {
+	tests := []struct {
+		user    int64
+		payload string
+		want    string
+	}{
+		{1, "bbb000000000", "Container stopped"},
+		{2, "aaa000000000", "Container stopped"},
+		{2, "bbb000000000", "does not exist"},
+	}
+	for _, tt := range tests {
+		engine := docker.NewFake().
+			AddContainer("aaa", "shop-web", "shop:1", "running", nil).
+			AddContainer("bbb", "blog-web", "blog:1", "running", nil)
+		bot := newFakeBot()
+		telegram, err := New(scopedConfig(), bot, engine, compose.NewFake())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		telegram.handleStop(&tb.Message{Chat: &tb.Chat{ID: tt.user}, Sender: &tb.User{ID: tt.user}, Payload: tt.payload})
+		if text := bot.last().Text; !strings.Contains(text, tt.want) {
+			t.Errorf("user %v /stop %v = %q, want %q in it", tt.user, tt.payload, text, tt.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// shellOutputSize is how much of the output tail is shown in the rolling message.
+const shellOutputSize = 3000
+
+// shellSession is an interactive shell bound to a chat and to the admin who opened it.
+type shellSession struct {
+	owner       int64
+	chat        *tb.Chat
+	containerID string
+	exec        *docker.ShellSession
+
+	mu         sync.Mutex
+	output     string
+	dirty      bool
+	message    *tb.Message
+	lastInput  time.Time
+	closeOnce  sync.Once
+	done       chan struct{}
+	exitReason string
+}
+
+// shellRegistry keeps track of the open shell of every chat.
+type shellRegistry struct {
+	mu       sync.Mutex
+	sessions map[int64]*shellSession
+}
+
+func newShellRegistry() *shellRegistry {
+	return &shellRegistry{sessions: map[int64]*shellSession{}}
+}
+
+func (r *shellRegistry) get(chatID int64) *shellSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sessions[chatID]
+}
+
+// add registers session unless the chat already has one.
+func (r *shellRegistry) add(session *shellSession) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.chat.ID]; ok {
+		return false
+	}
+	r.sessions[session.chat.ID] = session
+	return true
+}
+
+func (r *shellRegistry) remove(session *shellSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sessions[session.chat.ID] == session {
+		delete(r.sessions, session.chat.ID)
+	}
+}
+
+// handleShell opens an interactive shell. <ContainerID> [command]
+func (t *Telegram) handleShell(m *tb.Message) {
+	args, err := utils.SplitArgs(m.Payload)
+	if err != nil {
+		t.reply(m, html.EscapeString(err.Error()))
+		return
+	}
+	if len(args) < 1 || !t.dckr.IsValidID(args[0]) {
+		t.reply(m, "Usage: /shell <code>&lt;ContainerID&gt; [command]</code>")
+		return
+	}
+	if !t.replyScope(m, args[0]) {
+		return
+	}
+	if t.shells.get(m.Chat.ID) != nil {
+		t.reply(m, "There is already a shell open in this chat, close it with /exit first")
+		return
+	}
+
+	cmd := args[1:]
+	if len(cmd) == 0 {
+		cmd = []string{"/bin/sh"}
+	}
+	exec, err := t.dckr.Shell(args[0], cmd)
+	if err != nil {
+		t.replyError(m, err)
+		return
+	}
+
+	session := &shellSession{
+		owner:       m.Sender.ID,
+		chat:        m.Chat,
+		containerID: args[0],
+		exec:        exec,
+		lastInput:   time.Now(),
+		done:        make(chan struct{}),
+	}
+	if !t.shells.add(session) {
+		exec.Close()
+		t.reply(m, "There is already a shell open in this chat, close it with /exit first")
+		return
+	}
+	session.message = t.reply(m, t.renderShell(session))
+
+	go t.readShell(session)
+	go t.runShell(session)
+}
+
+// handleExit closes the shell open in the chat.
+func (t *Telegram) handleExit(m *tb.Message) {
+	session := t.shells.get(m.Chat.ID)
+	if session == nil || session.owner != m.Sender.ID {
+		return
+	}
+	t.closeShell(session, "closed")
+}
+
+// handleShellInput writes a plain text message to the shell of its chat.
+// It reports whether the message was consumed by a session.
+func (t *Telegram) handleShellInput(m *tb.Message) bool {
+	session := t.shells.get(m.Chat.ID)
+	if session == nil || session.owner != m.Sender.ID {
+		return false
+	}
+
+	session.mu.Lock()
+	session.lastInput = time.Now()
+	session.mu.Unlock()
+
+	entry := newAuditEntry(m.Chat, m.Sender, "shell", "shell")
+	// The input may be a password or a command holding one, only its size is recorded.
+	entry.Target, entry.Args = session.containerID, fmt.Sprintf("%v characters", utf8.RuneCountInString(m.Text))
+	if _, err := io.WriteString(session.exec.Stdin, m.Text+"\n"); err != nil {
+		log.Warn().Err(err).Str("containerID", session.containerID).Msg("error writing to shell")
+		entry.Result = err.Error()
+		t.closeShell(session, "lost connection")
+	}
+	t.recordAudit(entry)
+	return true
+}
+
+// readShell copies the shell output into the session buffer until the shell exits.
+func (t *Telegram) readShell(session *shellSession) {
+	buffer := make([]byte, 1024)
+	for {
+		numBytes, err := session.exec.Output.Read(buffer)
+		if numBytes > 0 {
+			session.mu.Lock()
+			session.output = tail(session.output+utils.StripANSI(string(buffer[:numBytes])), shellOutputSize)
+			session.dirty = true
+			session.mu.Unlock()
+		}
+		if err != nil {
+			t.closeShell(session, "exited")
+			return
+		}
+	}
+}
+
+// runShell flushes the output to the rolling message and enforces the idle timeout.
+func (t *Telegram) runShell(session *shellSession) {
+	// Telegram allows about one edit per second and chat.
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-session.done:
+			t.flushShell(session, true)
+			return
+		case <-ticker.C:
+			session.mu.Lock()
+			idle := time.Since(session.lastInput)
+			session.mu.Unlock()
+			if idle > t.cfg.ShellIdleTimeout {
+				t.closeShell(session, fmt.Sprintf("idle for %v", t.cfg.ShellIdleTimeout))
+				continue
+			}
+			t.flushShell(session, false)
+		}
+	}
+}
+
+// flushShell edits the rolling message with the latest output.
+func (t *Telegram) flushShell(session *shellSession, force bool) {
+	session.mu.Lock()
+	if (!session.dirty && !force) || session.message == nil {
+		session.mu.Unlock()
+		return
+	}
+	session.dirty = false
+	session.mu.Unlock()
+
+	if _, err := t.bot.Edit(session.message, t.renderShell(session), tb.ModeHTML); err != nil && !isNotModified(err) {
+		log.Warn().Err(err).Msg("error editing shell message")
+	}
+}
+
+func (t *Telegram) closeShell(session *shellSession, reason string) {
+	session.closeOnce.Do(func() {
+		t.shells.remove(session)
+		if err := session.exec.Close(); err != nil {
+			log.Debug().Err(err).Msg("error closing shell")
+		}
+		session.mu.Lock()
+		session.exitReason = reason
+		session.dirty = true
+		session.mu.Unlock()
+		close(session.done)
+	})
+}
+
+func (t *Telegram) renderShell(session *shellSession) string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	status := "open, send commands as messages and /exit to close"
+	if session.exitReason != "" {
+		status = session.exitReason
+	}
+	output := session.output
+	if output == "" {
+		output = " "
+	}
+	return fmt.Sprintf("Shell on <code>%v</code>: %v\n<pre>%v</pre>", session.containerID, status, html.EscapeString(output))
+}
+
+// tail returns the last size runes of s.
+func tail(s string, size int) string {
+	runes := []rune(s)
+	if len(runes) <= size {
+		return s
+	}
+	return string(runes[len(runes)-size:])
+}
+
+// handleText receives every plain text message that is not a command.
+func (t *Telegram) handleText(m *tb.Message) {
+	if t.handleShellInput(m) {
+		return
+	}
+	t.handleCreateInput(m)
+}
@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// stackActions maps the stack wide callbacks to the action they run on each container.
+var stackActions = map[string]string{
+	"stackstart":   "start",
+	"stackstop":    "stop",
+	"stackrestart": "restart",
+}
+
+// stackResults describes each stack wide action once done.
+var stackResults = map[string]string{
+	"start":   "started",
+	"stop":    "stopped",
+	"restart": "restarted",
+}
+
+// handleStack shows the services of a compose stack, or starts, stops or restarts all of them.
+// <name> [start|stop|restart]
+func (t *Telegram) handleStack(m *tb.Message) {
+	usage := "Usage: /stack <code><name> [start|stop|restart]</code>"
+	args := strings.Fields(m.Payload)
+	if len(args) == 0 || len(args) > 2 {
+		t.reply(m, usage)
+		return
+	}
+	name := args[0]
+	access := t.access(m.Chat, m.Sender)
+
+	if len(args) == 2 {
+		instruction := "stack" + args[1]
+		if _, ok := stackActions[instruction]; !ok {
+			t.reply(m, usage)
+			return
+		}
+		if required := t.requiredRole(callbackCommands[instruction]); access.Role < required {
+			t.reply(m, fmt.Sprintf("%v /stack %v requires the %v role, you are %v", emoji.NoEntry, args[1], required, access.Role))
+			return
+		}
+		if t.requireConfirmation(m, instruction, name) {
+			return
+		}
+		text, err := t.runStackAction(access, name, instruction)
+		if err != nil {
+			t.replyError(m, err)
+			return
+		}
+		t.reply(m, text)
+		return
+	}
+
+	containers, err := t.stackContainers(access, name)
+	if err != nil {
+		t.replyError(m, err)
+		return
+	}
+	t.reply(m, strings.Join(utils.FormatStack(name, containers), "\n\n"), t.makeStackMenu(m.Sender.ID, name))
+}
+
+// makeStackMenu builds the stack wide actions keyboard of a stack.
+func (t *Telegram) makeStackMenu(owner int64, name string) *tb.ReplyMarkup {
+	return t.newMenu(owner, [][]tb.InlineButton{{
+		{Text: "Start all", Data: fmt.Sprintf("stackstart:%v", name)},
+		{Text: "Stop all", Data: fmt.Sprintf("stackstop:%v", name)},
+		{Text: "Restart all", Data: fmt.Sprintf("stackrestart:%v", name)},
+	}})
+}
+
+// handleStackCallback runs a stack wide action from the /stack menu.
+func (t *Telegram) handleStackCallback(c *tb.Callback, instruction string, name string) {
+	text, err := t.runStackAction(t.access(c.Message.Chat, c.Sender), name, instruction)
+	t.callbackResponse(c, err, name, text)
+}
+
+// stackContainers returns the containers of the stack within reach of access.
+// Stacks without any are reported as not found.
+func (t *Telegram) stackContainers(access config.Access, name string) ([]types.Container, error) {
+	stacks, err := t.dckr.ListCompose()
+	if err != nil {
+		return nil, err
+	}
+	containers := filterContainers(access, stacks[name])
+	if len(containers) == 0 {
+		return nil, &docker.Error{Op: "stack", ContainerID: name, Kind: docker.ErrNotFound}
+	}
+	return containers, nil
+}
+
+// runStackAction runs the action of instruction on every container of the stack within
+// reach of access. Containers are started dependencies first and stopped dependencies
+// last, the containers of each level at once.
+func (t *Telegram) runStackAction(access config.Access, name string, instruction string) (string, error) {
+	containers, err := t.stackContainers(access, name)
+	if err != nil {
+		return "", err
+	}
+	action := stackActions[instruction]
+	levels := docker.StackLevels(containers)
+	if action == "stop" {
+		for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+			levels[i], levels[j] = levels[j], levels[i]
+		}
+	}
+
+	var mu sync.Mutex
+	done, failures := 0, []string{}
+	for _, level := range levels {
+		var wg sync.WaitGroup
+		for _, container := range level {
+			// Starting a running container or stopping a stopped one is a no-op.
+			if (action == "start" && container.State == "running") || (action == "stop" && container.State != "running") {
+				continue
+			}
+			wg.Add(1)
+			go func(container types.Container) {
+				defer wg.Done()
+				err := t.runContainerAction(action, container.ID)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failures = append(failures, fmt.Sprintf("<code>%v</code>: %v", html.EscapeString(container.Names[0][1:]), html.EscapeString(cause(err))))
+				} else {
+					done++
+				}
+			}(container)
+		}
+		wg.Wait()
+	}
+
+	text := fmt.Sprintf("Stack <code>%v</code>: %v of %v containers %v", html.EscapeString(name), done, len(containers), stackResults[action])
+	if len(failures) > 0 {
+		text = fmt.Sprintf("%v\n%v Failed:\n%v", text, emoji.CrossMark, strings.Join(failures, "\n"))
+	}
+	return text, nil
+}
+
+// runContainerAction runs start, stop or restart on a container.
+func (t *Telegram) runContainerAction(action string, containerID string) error {
+	switch action {
+	case "start":
+		return t.dckr.Start(containerID)
+	case "stop":
+		return t.dckr.Stop(containerID)
+	default:
+		return t.dckr.Restart(containerID)
+	}
+}
+
+// stackHoldsSelf reports whether teledock runs in one of the containers of the stack.
+func (t *Telegram) stackHoldsSelf(name string) bool {
+	stacks, err := t.dckr.ListCompose()
+	if err != nil {
+		return false
+	}
+	for _, container := range stacks[name] {
+		if t.dckr.IsSelf(container.ID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/mrmarble/teledock/internal/constants"
+	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// statsMessageSize is how much of the stats of many containers goes in each
+// message, telegram takes up to 4096 characters.
+const statsMessageSize = 3000
+
+// statsSorters order /top by each column, highest first.
+var statsSorters = map[string]func(a, b *docker.Stats) bool{
+	"cpu": func(a, b *docker.Stats) bool { return a.CPUPercent > b.CPUPercent },
+	"mem": func(a, b *docker.Stats) bool { return a.MemoryUsage > b.MemoryUsage },
+	"net": func(a, b *docker.Stats) bool { return a.NetRx+a.NetTx > b.NetRx+b.NetTx },
+	"io":  func(a, b *docker.Stats) bool { return a.BlockRead+a.BlockWrite > b.BlockRead+b.BlockWrite },
+}
+
+// handleStats shows the resource usage of a container, or of every running container. [ContainerID]
+func (t *Telegram) handleStats(m *tb.Message) {
+	containerID := strings.TrimSpace(m.Payload)
+	if containerID != "" {
+		if !t.dckr.IsValidID(containerID) {
+			t.reply(m, "Usage: /stats <code>[ContainerID]</code>")
+			return
+		}
+		if !t.replyScope(m, containerID) {
+			return
+		}
+		stats, err := t.dckr.Stats(containerID)
+		if err != nil {
+			t.replyError(m, err)
+			return
+		}
+		t.reply(m, strings.Join(formatStatsList([]*docker.Stats{stats}), "\n\n"))
+		return
+	}
+
+	stats, err := t.runningStats(m)
+	if err != nil {
+		t.replyError(m, err)
+		return
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	t.replyMessages(m, chunkBlocks(formatStatsList(stats), statsMessageSize))
+}
+
+// handleTop shows a table of the resource usage of the running containers. [cpu|mem|net|io]
+func (t *Telegram) handleTop(m *tb.Message) {
+	column := strings.ToLower(strings.TrimSpace(m.Payload))
+	if column == "" {
+		column = "cpu"
+	}
+	sorter, ok := statsSorters[column]
+	if !ok {
+		t.reply(m, "Usage: /top <code>[cpu|mem|net|io]</code>")
+		return
+	}
+
+	stats, err := t.runningStats(m)
+	if err != nil {
+		t.replyError(m, err)
+		return
+	}
+	if len(stats) == 0 {
+		t.reply(m, "No containers running")
+		return
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return sorter(stats[i], stats[j]) })
+	t.replyMessages(m, formatStatsTable(stats))
+}
+
+// runningStats samples the running containers the sender can reach, all at once
+// since every sample takes about a second. Containers failing to report are left out.
+func (t *Telegram) runningStats(m *tb.Message) ([]*docker.Stats, error) {
+	containers, err := t.dckr.List(types.ContainerListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	containers = filterContainers(t.access(m.Chat, m.Sender), containers)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	stats := []*docker.Stats{}
+	for _, container := range containers {
+		wg.Add(1)
+		go func(containerID string) {
+			defer wg.Done()
+			sample, err := t.dckr.Stats(containerID)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			stats = append(stats, sample)
+			mu.Unlock()
+		}(container.ID)
+	}
+	wg.Wait()
+	return stats, nil
+}
+
+// replyMessages replies with the first message and sends the rest after it.
+func (t *Telegram) replyMessages(m *tb.Message, messages []string) {
+	for index, message := range messages {
+		if index == 0 {
+			t.reply(m, message)
+		} else {
+			t.send(m.Chat, message)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// chunkBlocks joins blocks of HTML with blank lines into chunks of about size
+// bytes. Blocks are never split so their tags stay balanced.
+func chunkBlocks(blocks []string, size int) []string {
+	chunks := []string{}
+	current := ""
+	for _, block := range blocks {
+		if current != "" && len(current)+2+len(block) > size {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n\n"
+		}
+		current += block
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// formatStatsList formats the resource usage of each container in the padded layout.
+func formatStatsList(stats []*docker.Stats) []string {
+	if len(stats) == 0 {
+		return []string{"No containers running"}
+	}
+	resultMsg := make([]string, 0, len(stats))
+	for _, sample := range stats {
+		resultMsg = append(resultMsg, strings.Join([]string{
+			fmt.Sprintf("<b>%v</b>", html.EscapeString(sample.Name)),
+			fmt.Sprintf(constants.FormatedStrPadded, "CPU:", fmt.Sprintf("%.1f%%", sample.CPUPercent)),
+			fmt.Sprintf(constants.FormatedStrPadded, "MEM:", fmt.Sprintf("%v / %v (%.1f%%)", utils.FormatBytes(sample.MemoryUsage), utils.FormatBytes(sample.MemoryLimit), sample.MemoryPercent)),
+			fmt.Sprintf(constants.FormatedStrPadded, "NET:", fmt.Sprintf("%v / %v", utils.FormatBytes(sample.NetRx), utils.FormatBytes(sample.NetTx))),
+			fmt.Sprintf(constants.FormatedStrPadded, "BLOCK:", fmt.Sprintf("%v / %v", utils.FormatBytes(sample.BlockRead), utils.FormatBytes(sample.BlockWrite))),
+		}, "\n"))
+	}
+	return resultMsg
+}
+
+// formatStatsTable formats the resource usage of the containers as a table, one
+// line per container, split in as many messages as needed.
+func formatStatsTable(stats []*docker.Stats) []string {
+	lines := []string{fmt.Sprintf("%-14v %6v %9v %5v %19v %19v", "NAME", "CPU", "MEM", "MEM%", "NET I/O", "BLOCK I/O")}
+	for _, sample := range stats {
+		name := sample.Name
+		if runes := []rune(name); len(runes) > 14 {
+			name = string(runes[:13]) + "…"
+		}
+		lines = append(lines, fmt.Sprintf("%-14v %5.1f%% %9v %4.0f%% %19v %19v", name, sample.CPUPercent,
+			utils.FormatBytes(sample.MemoryUsage), sample.MemoryPercent,
+			utils.FormatBytes(sample.NetRx)+" / "+utils.FormatBytes(sample.NetTx),
+			utils.FormatBytes(sample.BlockRead)+" / "+utils.FormatBytes(sample.BlockWrite)))
+	}
+	messages := []string{}
+	for _, chunk := range utils.ChunkLines(lines, statsMessageSize) {
+		messages = append(messages, fmt.Sprintf("<pre>%v</pre>", html.EscapeString(chunk)))
+	}
+	return messages
+}
@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestChunkBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks []string
+		want   []string
+	}{
+		{"none", nil, []string{}},
+		{"one chunk", []string{"aaa", "bbb"}, []string{"aaa\n\nbbb"}},
+		{"split between blocks", []string{"aaa", "bbb", "ccc"}, []string{"aaa\n\nbbb", "ccc"}},
+		{"block over the size", []string{"aaaaaaaaaaaa", "b"}, []string{"aaaaaaaaaaaa", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkBlocks(tt.blocks, 10); fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Errorf("chunkBlocks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleStatsAll(t *testing.T) {
+	engine := docker.NewFake()
+	for index := 0; index < 40; index++ {
+		engine.AddContainer(fmt.Sprintf("a%011d", index), fmt.Sprintf("container-with-a-long-name-%02d", index), "nginx", "running", nil)
+	}
+	bot := newFakeBot()
+	telegram, err := New(&config.Config{Users: []config.User{{ID: 1, Role: config.RoleAdmin}}}, bot, engine, compose.NewFake())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	telegram.handleStats(&tb.Message{Chat: &tb.Chat{ID: 1}, Sender: &tb.User{ID: 1}})
+	sent := bot.sent()
+	if len(sent) < 2 {
+		t.Fatalf("sent %v messages, want the stats split", len(sent))
+	}
+	names := 0
+	for _, message := range sent {
+		if len(message.Text) > 4096 {
+			t.Errorf("sent %v characters", len(message.Text))
+		}
+		names += strings.Count(message.Text, "container-with-a-long-name-")
+	}
+	if names != 40 {
+		t.Errorf("sent the stats of %v containers, want 40", names)
+	}
+}
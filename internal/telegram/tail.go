@@ -0,0 +1,228 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// followInterval is how often a followed log message is edited, telegram
+	// rate limits edits so new lines are batched in between.
+	followInterval = 3 * time.Second
+	// followOutputSize is how much of the log tail a followed log message shows.
+	followOutputSize = 3000
+	// maxFollowsPerChat caps the concurrent /tail streams of a chat.
+	maxFollowsPerChat = 5
+)
+
+// logFollow is a /tail stream bound to a message.
+type logFollow struct {
+	token       string
+	chatID      int64
+	owner       int64
+	containerID string
+	cancel      context.CancelFunc
+}
+
+// followRegistry keeps track of the /tail streams of every chat.
+type followRegistry struct {
+	mu      sync.Mutex
+	follows map[int64]map[string]*logFollow
+}
+
+func newFollowRegistry() *followRegistry {
+	return &followRegistry{follows: map[int64]map[string]*logFollow{}}
+}
+
+// add registers follow unless its chat reached maxFollowsPerChat.
+func (r *followRegistry) add(follow *logFollow) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chat, ok := r.follows[follow.chatID]
+	if !ok {
+		chat = map[string]*logFollow{}
+		r.follows[follow.chatID] = chat
+	}
+	if len(chat) >= maxFollowsPerChat {
+		return false
+	}
+	chat[follow.token] = follow
+	return true
+}
+
+// remove unregisters the follow with token and returns it, nil if it is not running.
+func (r *followRegistry) remove(chatID int64, token string) *logFollow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	follow, ok := r.follows[chatID][token]
+	if !ok {
+		return nil
+	}
+	delete(r.follows[chatID], token)
+	if len(r.follows[chatID]) == 0 {
+		delete(r.follows, chatID)
+	}
+	return follow
+}
+
+// list returns the follows running in a chat.
+func (r *followRegistry) list(chatID int64) []*logFollow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	follows := []*logFollow{}
+	for _, follow := range r.follows[chatID] {
+		follows = append(follows, follow)
+	}
+	return follows
+}
+
+// handleTail follows the logs of a container. <ContainerID>
+func (t *Telegram) handleTail(m *tb.Message) {
+	containerID := strings.TrimSpace(m.Payload)
+	if containerID == "" || !t.dckr.IsValidID(containerID) {
+		filters := filters.NewArgs()
+		filters.Add("status", "running")
+		t.askForContainer(m, types.ContainerListOptions{Filters: filters}, "tail")
+		return
+	}
+	if !t.replyScope(m, containerID) {
+		return
+	}
+	message := t.reply(m, fmt.Sprintf("Following logs of <code>%v</code>...", containerID))
+	if message != nil {
+		t.startFollow(message, m.Sender.ID, containerID)
+	}
+}
+
+// handleUntail stops the /tail streams the sender started in the chat, admins stop
+// every stream of the chat.
+func (t *Telegram) handleUntail(m *tb.Message) {
+	admin := t.access(m.Chat, m.Sender).Role >= config.RoleAdmin
+	stopped := 0
+	for _, follow := range t.follows.list(m.Chat.ID) {
+		if (admin || follow.owner == m.Sender.ID) && t.stopFollow(m.Chat.ID, follow.token) {
+			stopped++
+		}
+	}
+	t.reply(m, fmt.Sprintf("Stopped %v log streams", stopped))
+}
+
+// startFollow streams the logs of a container into message, stoppable by owner.
+func (t *Telegram) startFollow(message *tb.Message, owner int64, containerID string) {
+	bytes := make([]byte, 4)
+	if _, err := rand.Read(bytes); err != nil {
+		log.Error().Err(err).Msg("error generating follow token")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	follow := &logFollow{
+		token:       hex.EncodeToString(bytes),
+		chatID:      message.Chat.ID,
+		owner:       owner,
+		containerID: containerID,
+		cancel:      cancel,
+	}
+	if !t.follows.add(follow) {
+		cancel()
+		t.editFollow(message, follow, "Too many log streams in this chat, stop one first or use /untail", false)
+		return
+	}
+
+	lines, err := t.dckr.FollowLogs(ctx, containerID)
+	if err != nil {
+		t.follows.remove(follow.chatID, follow.token)
+		cancel()
+		t.editFollow(message, follow, formatError(err), false)
+		return
+	}
+
+	go t.runFollow(ctx, message, follow, lines)
+}
+
+// runFollow batches the incoming lines into edits of message until the stream ends.
+func (t *Telegram) runFollow(ctx context.Context, message *tb.Message, follow *logFollow, lines <-chan docker.LogLine) {
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+
+	output, dirty := "", true
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.follows.remove(follow.chatID, follow.token)
+				follow.cancel()
+				status := "stream ended"
+				if ctx.Err() != nil {
+					status = "stopped"
+				}
+				t.editFollow(message, follow, t.renderFollow(follow, output, status), false)
+				return
+			}
+			text := utils.StripANSI(line.Text)
+			if line.Stream == docker.Stderr {
+				text = stderrLabel + text
+			}
+			output = tail(output+text+"\n", followOutputSize)
+			dirty = true
+		case <-ticker.C:
+			if dirty {
+				t.editFollow(message, follow, t.renderFollow(follow, output, "following"), true)
+				dirty = false
+			}
+		}
+	}
+}
+
+// stopFollow cancels a /tail stream, its goroutine writes the final edit.
+func (t *Telegram) stopFollow(chatID int64, token string) bool {
+	follow := t.follows.remove(chatID, token)
+	if follow == nil {
+		return false
+	}
+	follow.cancel()
+	return true
+}
+
+// handleUntailCallback answers the Stop button of a followed log message.
+func (t *Telegram) handleUntailCallback(c *tb.Callback, token string) {
+	text := ""
+	if !t.stopFollow(c.Message.Chat.ID, token) {
+		text = "This log stream already ended"
+	}
+	if err := t.bot.Respond(c, &tb.CallbackResponse{Text: text}); err != nil {
+		log.Error().Err(err).Msg("error replying to callback")
+	}
+}
+
+func (t *Telegram) renderFollow(follow *logFollow, output string, status string) string {
+	if output == "" {
+		output = " "
+	}
+	return fmt.Sprintf("Logs of <code>%v</code>: %v\n<pre>%v</pre>", follow.containerID, status, html.EscapeString(output))
+}
+
+// editFollow edits a followed log message, with a Stop button while it is running.
+func (t *Telegram) editFollow(message *tb.Message, follow *logFollow, text string, running bool) {
+	options := []interface{}{tb.ModeHTML}
+	if running {
+		options = append(options, t.newMenu(follow.owner, [][]tb.InlineButton{{{Text: "Stop", Data: fmt.Sprintf("untail:%v", follow.token)}}}))
+	}
+	if _, err := t.bot.Edit(message, text, options...); err != nil && !isNotModified(err) {
+		log.Warn().Err(err).Msg("error editing followed logs")
+	}
+}
@@ -0,0 +1,55 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestHandleUntail(t *testing.T) {
+	const (
+		group = -100
+		admin = 1
+		alice = 2
+		bob   = 3
+	)
+	tests := []struct {
+		name   string
+		caller int64
+		left   map[int64]int
+	}{
+		{"viewer stops its own streams", alice, map[int64]int{alice: 0, bob: 1}},
+		{"admin stops every stream", admin, map[int64]int{alice: 0, bob: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := docker.NewFake().AddContainer("aaa", "web", "nginx", "running", nil)
+			cfg := &config.Config{Users: []config.User{
+				{ID: admin, Role: config.RoleAdmin},
+				{ID: alice, Role: config.RoleViewer},
+				{ID: bob, Role: config.RoleViewer},
+			}}
+			telegram, err := New(cfg, newFakeBot(), engine, compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, owner := range []int64{alice, alice, bob} {
+				telegram.startFollow(&tb.Message{ID: 10, Chat: &tb.Chat{ID: group}}, owner, "aaa000000000")
+			}
+
+			telegram.handleUntail(&tb.Message{Chat: &tb.Chat{ID: group}, Sender: &tb.User{ID: tt.caller}})
+			left := map[int64]int{alice: 0, bob: 0}
+			for _, follow := range telegram.follows.list(group) {
+				left[follow.owner]++
+			}
+			for owner, want := range tt.left {
+				if left[owner] != want {
+					t.Errorf("user %v has %v streams left, want %v", owner, left[owner], want)
+				}
+			}
+		})
+	}
+}
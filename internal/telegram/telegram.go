@@ -1,26 +1,83 @@
 package telegram
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
+	"html"
 	"math"
+	"strings"
 	"time"
 
 	tb "gopkg.in/tucnak/telebot.v2"
 
 	"github.com/docker/docker/api/types"
+	"github.com/enescakir/emoji"
+	"github.com/mrmarble/teledock/internal/audit"
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
 	"github.com/mrmarble/teledock/internal/docker"
+	"github.com/mrmarble/teledock/internal/utils"
 	"github.com/rs/zerolog"
 	zero "github.com/rs/zerolog/log"
 )
 
 var log zerolog.Logger
 
+// destructiveActions are the callbacks and compose commands that can take a container down.
+var destructiveActions = map[string]bool{
+	"stop":    true,
+	"kill":    true,
+	"pause":   true,
+	"restart": true,
+	"down":    true,
+}
+
+// containerCallbacks are the callbacks whose payload is a container ID.
+var containerCallbacks = map[string]bool{
+	"stop":      true,
+	"start":     true,
+	"restart":   true,
+	"pause":     true,
+	"unpause":   true,
+	"kill":      true,
+	"norestart": true,
+	"inspect":   true,
+	"logs":      true,
+	"tail":      true,
+	"navc":      true,
+	"navg":      true,
+	"navi":      true,
+	"navr":      true,
+}
+
 // Telegram represents the telegram bot.
 type Telegram struct {
-	bot                *tb.Bot
-	dckr               docker.Docker
+	bot                Bot
+	dckr               docker.ContainerEngine
+	cfg                *config.Config
 	handlersRegistered bool
-	admins             []int64
+	shells             *shellRegistry
+	wizards            *wizardRegistry
+	confirms           *confirmRegistry
+	follows            *followRegistry
+	audits             *auditTracker
+	// audit is the audit log file, nil when disabled.
+	audit *audit.Log
+	// roles holds the role each command requires before config overrides.
+	roles map[string]config.Role
+	// callbackKey signs the data of inline buttons.
+	callbackKey []byte
+	// longCallbacks keeps the data of inline buttons too long for telegram.
+	longCallbacks *callbackStore
+	// sampler keeps the resource usage history of the containers, nil when disabled.
+	sampler *docker.Sampler
+	alerts  *alertRegistry
+	// outbox sends the notifications and audit entries nobody is waiting for.
+	outbox *outbox
+	// composer runs the compose commands of the projects in the config.
+	composer compose.Backend
 }
 
 // Command represent a telegram command.
@@ -28,15 +85,18 @@ type Command struct {
 	Cmd         string
 	Aliases     []string
 	Description string
-	Handler     interface{}
+	Handler     func(*tb.Message)
+	// Role is the lowest role allowed to run the command, unless overridden in the config.
+	// Commands without one are public.
+	Role config.Role
 }
 
-// NewBot returns a Telegram bot.
-func NewBot(token string, dckr docker.Docker, admins []int64) (*Telegram, error) {
+// NewBot returns a Telegram bot connected to the telegram API.
+func NewBot(cfg *config.Config, dckr docker.ContainerEngine, composer compose.Backend) (*Telegram, error) {
 	log = zero.With().Str("package", "Telegram").Logger()
 
 	bot, err := tb.NewBot(tb.Settings{
-		Token:  token,
+		Token:  cfg.Token,
 		Poller: &tb.LongPoller{Timeout: 10 * time.Second},
 		Reporter: func(err error) {
 			log.Error().Err(err).Msg("telebot internal error")
@@ -49,12 +109,45 @@ func NewBot(token string, dckr docker.Docker, admins []int64) (*Telegram, error)
 
 	log.Info().Int64("id", bot.Me.ID).Str("name", bot.Me.FirstName).Str("username", bot.Me.Username).Msg("connected to telegram")
 
-	return &Telegram{bot: bot, dckr: dckr, admins: admins}, nil
+	return New(cfg, bot, dckr, composer)
+}
+
+// New returns a Telegram bot talking to telegram through bot.
+func New(cfg *config.Config, bot Bot, dckr docker.ContainerEngine, composer compose.Backend) (*Telegram, error) {
+	log = zero.With().Str("package", "Telegram").Logger()
+
+	var (
+		auditLog *audit.Log
+		err      error
+	)
+	if cfg.AuditFile != "" {
+		if auditLog, err = audit.Open(cfg.AuditFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var sampler *docker.Sampler
+	if cfg.StatsInterval > 0 {
+		sampler = docker.NewSampler(dckr, cfg.StatsInterval, cfg.StatsRetention)
+	}
+
+	t := &Telegram{bot: bot, dckr: dckr, cfg: cfg, audit: auditLog, sampler: sampler, alerts: newAlertRegistry(), composer: composer, audits: newAuditTracker(), roles: map[string]config.Role{}, callbackKey: newCallbackKey(cfg.CallbackSecret), longCallbacks: newCallbackStore(), shells: newShellRegistry(), wizards: newWizardRegistry(), confirms: newConfirmRegistry(), follows: newFollowRegistry()}
+	t.outbox = newOutbox(t.send)
+	return t, nil
 }
 
 // Start starts polling for telegram updates.
 func (t *Telegram) Start() {
 	t.registerHandlers()
+	if len(t.cfg.Notifications) > 0 {
+		go t.watchEvents()
+	}
+	if t.sampler != nil {
+		if len(t.cfg.Alerts) > 0 {
+			t.sampler.OnSample(t.checkAlerts)
+		}
+		go t.sampler.Run(context.Background())
+	}
 
 	log.Info().Msg("start polling")
 	t.bot.Start()
@@ -81,45 +174,156 @@ func (t *Telegram) registerHandlers() {
 			Cmd:         "ps",
 			Aliases:     []string{"ls", "list"},
 			Description: "List running containers",
+			Role:        config.RoleViewer,
 		},
 		{
 			Handler:     t.handleListAll,
 			Cmd:         "psa",
 			Aliases:     []string{"lsa", "listall"},
 			Description: "List all containers",
+			Role:        config.RoleViewer,
 		},
 		{
 			Handler:     t.handleStop,
 			Cmd:         "stop",
 			Aliases:     []string{"down"},
 			Description: "Stop a running container. <ContainerID>",
+			Role:        config.RoleOperator,
 		},
 		{
 			Handler:     t.handleStartContainer,
 			Cmd:         "run",
 			Description: "Start a stopped container. <ContainerID>",
+			Role:        config.RoleOperator,
+		},
+		{
+			Handler:     t.handleCreate,
+			Cmd:         "create",
+			Description: "Create a new container. <image>",
+			Role:        config.RoleAdmin,
+		},
+		{
+			Handler:     t.handleRestart,
+			Cmd:         "restart",
+			Description: "Restart a container. <ContainerID>",
+			Role:        config.RoleOperator,
+		},
+		{
+			Handler:     t.handlePause,
+			Cmd:         "pause",
+			Description: "Pause a running container. <ContainerID>",
+			Role:        config.RoleOperator,
+		},
+		{
+			Handler:     t.handleUnpause,
+			Cmd:         "unpause",
+			Description: "Unpause a paused container. <ContainerID>",
+			Role:        config.RoleOperator,
+		},
+		{
+			Handler:     t.handleKill,
+			Cmd:         "kill",
+			Description: "Kill a running container. <ContainerID> <signal>",
+			Role:        config.RoleOperator,
+		},
+		{
+			Handler:     t.handleExec,
+			Cmd:         "exec",
+			Description: "Run a command inside a container. <ContainerID> <command>",
+			Role:        config.RoleAdmin,
+		},
+		{
+			Handler:     t.handleShell,
+			Cmd:         "shell",
+			Description: "Open an interactive shell inside a container. <ContainerID> <command>",
+			Role:        config.RoleAdmin,
+		},
+		{
+			Handler:     t.handleExit,
+			Cmd:         "exit",
+			Description: "Close the shell open in this chat",
+			Role:        config.RoleAdmin,
 		},
 		{
 			Handler:     t.handleInspect,
 			Cmd:         "inspect",
 			Aliases:     []string{"describe"},
 			Description: "Inspect a container. <ContainerID>",
+			Role:        config.RoleViewer,
 		},
 		{
 			Handler:     t.handleStacks,
 			Cmd:         "stacks",
 			Aliases:     []string{"lss", "liststacks"},
 			Description: "Lists all compose stacks",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleStack,
+			Cmd:         "stack",
+			Description: "Shows the services of a compose stack, or starts, stops or restarts all of them. <name> [start|stop|restart]",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleCompose,
+			Cmd:         "compose",
+			Description: "Lists the compose projects, or runs a compose command on one. [project] [up|down|pull|config]",
+			Role:        config.RoleAdmin,
 		},
 		{
 			Handler:     t.handleLogs,
 			Cmd:         "logs",
-			Description: "Shows container logs. <ContainerID> <tail>",
+			Description: "Shows container logs. <ContainerID> <tail> [--since 1h] [--until 10m] [--grep pattern] [--timestamps] [--stdout|--stderr]. With --grep the tail counts the matching lines",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleTail,
+			Cmd:         "tail",
+			Aliases:     []string{"follow"},
+			Description: "Follow container logs. <ContainerID>",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleUntail,
+			Cmd:         "untail",
+			Description: "Stop the logs you follow in this chat",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleStats,
+			Cmd:         "stats",
+			Description: "Shows the resource usage of the running containers. [ContainerID]",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleTop,
+			Cmd:         "top",
+			Description: "Lists the running containers by resource usage. [cpu|mem|net|io]",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleGraph,
+			Cmd:         "graph",
+			Description: "Charts the resource usage history of a container. <ContainerID> [cpu|mem|net] [1h]",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleAlerts,
+			Cmd:         "alerts",
+			Description: "Lists the resource usage alert rules. [mute|unmute] [rule] [duration]",
+			Role:        config.RoleOperator,
 		},
 		{
 			Handler:     t.handleImageList,
 			Cmd:         "images",
 			Description: "List all installed images",
+			Role:        config.RoleViewer,
+		},
+		{
+			Handler:     t.handleAudit,
+			Cmd:         "audit",
+			Description: "Shows the last actions taken through the bot. [n] [user]",
+			Role:        config.RoleAdmin,
 		},
 	}
 	for _, command := range botCommands {
@@ -127,13 +331,26 @@ func (t *Telegram) registerHandlers() {
 			Text:        command.Cmd,
 			Description: command.Description,
 		})
+		t.roles[command.Cmd] = command.Role
+		handler := t.authorize(command)
 		for _, alias := range command.Aliases {
-			t.bot.Handle(fmt.Sprintf("/%s", alias), command.Handler)
+			t.bot.Handle(fmt.Sprintf("/%s", alias), handler)
+		}
+		t.bot.Handle(fmt.Sprintf("/%s", command.Cmd), handler)
+	}
+
+	for cmd := range t.cfg.Commands {
+		known := false
+		for _, command := range botCommands {
+			known = known || command.Cmd == cmd
+		}
+		if !known {
+			log.Warn().Str("command", cmd).Msg("role configured for an unknown command")
 		}
-		t.bot.Handle(fmt.Sprintf("/%s", command.Cmd), command.Handler)
 	}
 
 	t.bot.Handle(tb.OnCallback, t.handleCallback)
+	t.bot.Handle(tb.OnText, t.handleText)
 
 	if err := t.bot.SetCommands(botCommandList); err != nil {
 		log.Fatal().Err(err).Msg("error registering commands")
@@ -142,14 +359,37 @@ func (t *Telegram) registerHandlers() {
 	t.handlersRegistered = true
 }
 
-func (t *Telegram) isSuperAdmin(user *tb.User) bool {
-	for _, uid := range t.admins {
-		if user.ID == uid {
-			return true
+// authorize wraps the handler of command so it only runs for users with the role it
+// requires in the chat, recording it to the audit log.
+func (t *Telegram) authorize(command Command) func(*tb.Message) {
+	required := t.requiredRole(command.Cmd)
+	return func(m *tb.Message) {
+		entry := newAuditEntry(m.Chat, m.Sender, "command", command.Cmd)
+		entry.Target, entry.Args = t.auditTarget(m.Payload)
+
+		role := t.access(m.Chat, m.Sender).Role
+		if required != config.RoleNone && (role < required || m.Sender == nil) {
+			if role != config.RoleNone {
+				t.reply(m, fmt.Sprintf("%v /%v requires the %v role, you are %v", emoji.NoEntry, command.Cmd, required, role))
+			}
+			log.Warn().Int64("chat", m.Chat.ID).Str("command", command.Cmd).Msg("unauthorized command")
+			entry.Result = "denied"
+			t.recordAudit(entry)
+			return
 		}
+
+		t.audits.begin(messageKey(m), entry)
+		command.Handler(m)
+		t.endAudit(messageKey(m))
 	}
+}
 
-	return false
+// requiredRole returns the lowest role allowed to run cmd.
+func (t *Telegram) requiredRole(cmd string) config.Role {
+	if role, ok := t.cfg.Commands[cmd]; ok {
+		return role
+	}
+	return t.roles[cmd]
 }
 
 // send sends a message with error logging and retries.
@@ -218,69 +458,155 @@ func (t *Telegram) reply(to *tb.Message, what interface{}, options ...interface{
 	}
 }
 
-func (t *Telegram) makeContainerMenu(options types.ContainerListOptions, callback string) *tb.ReplyMarkup {
+// isLargeOutput reports whether lines are sent as a file rather than as messages.
+func (t *Telegram) isLargeOutput(lines []string) bool {
+	size := 0
+	for _, line := range lines {
+		size += len([]rune(line)) + 1
+	}
+	return size > t.cfg.DocumentThreshold
+}
+
+// replyOutput replies with lines split in as many <code> messages as needed, or
+// attached as fileName with caption when they are too long.
+func (t *Telegram) replyOutput(m *tb.Message, fileName string, caption string, lines []string) {
+	if t.isLargeOutput(lines) {
+		t.reply(m, t.makeDocument(fileName, caption, lines))
+		return
+	}
+	for index, chunk := range utils.ChunkLines(lines, 3000) {
+		if index == 0 {
+			t.reply(m, fmt.Sprintf(FormatedStr, html.EscapeString(chunk)), tb.ModeHTML)
+		} else {
+			t.send(m.Chat, fmt.Sprintf(FormatedStr, html.EscapeString(chunk)), tb.ModeHTML)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// callbackOutput is replyOutput for callbacks, the first message replaces the menu.
+func (t *Telegram) callbackOutput(c *tb.Callback, payload string, fileName string, caption string, lines []string) {
+	if t.isLargeOutput(lines) {
+		t.callbackResponse(c, nil, payload, caption)
+		t.send(c.Message.Chat, t.makeDocument(fileName, caption, lines))
+		return
+	}
+	for index, chunk := range utils.ChunkLines(lines, 3000) {
+		if index == 0 {
+			t.callbackResponse(c, nil, payload, fmt.Sprintf(FormatedStr, html.EscapeString(chunk)))
+		} else {
+			t.send(c.Message.Chat, fmt.Sprintf(FormatedStr, html.EscapeString(chunk)), tb.ModeHTML)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// makeDocument builds a file out of lines, gzipped if configured.
+func (t *Telegram) makeDocument(fileName string, caption string, lines []string) *tb.Document {
+	content := []byte(strings.Join(lines, "\n"))
+	mime := "text/plain"
+	if strings.HasSuffix(fileName, ".json") {
+		mime = "application/json"
+	}
+
+	if t.cfg.DocumentGzip {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		_, err := writer.Write(content)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("error compressing document, sending it uncompressed")
+		} else {
+			content, fileName, mime = buffer.Bytes(), fileName+".gz", "application/gzip"
+		}
+	}
+
+	return &tb.Document{
+		File:     tb.FromReader(bytes.NewReader(content)),
+		FileName: fileName,
+		MIME:     mime,
+		Caption:  caption,
+	}
+}
+
+// makeContainerMenu builds an inline keyboard with one button per container user can reach in chat.
+// The buttons only work for user.
+// Pressing a button sends "callback:containerID[:args...]" to handleCallback.
+func (t *Telegram) makeContainerMenu(chat *tb.Chat, user *tb.User, options types.ContainerListOptions, callback string, args ...string) (*tb.ReplyMarkup, error) {
 	buttonsPerRow := 3
-	containers := t.dckr.List(options)
+	containers, err := t.dckr.List(options)
+	if err != nil {
+		return nil, err
+	}
+	containers = filterContainers(t.access(chat, user), containers)
+
+	if destructiveActions[callback] {
+		visible := []types.Container{}
+		for _, container := range containers {
+			if !t.dckr.IsSelf(container.ID) {
+				visible = append(visible, container)
+			}
+		}
+		containers = visible
+	}
 
-	menu := t.bot.NewMarkup()
 	rowNumber := int(math.Ceil(float64(len(containers)) / float64(buttonsPerRow)))
 	buttons := []tb.InlineButton{}
-	rows := make([][]tb.InlineButton, rowNumber)
+	rows := make([][]tb.InlineButton, 0, rowNumber)
 	for index, container := range containers {
 		if index != 0 && index%buttonsPerRow == 0 {
 			rows = append(rows, buttons)
 			buttons = nil
 		}
 
-		btn := menu.Data(container.Names[0][1:], fmt.Sprintf("%v:%v", index, container.ID[:10]), fmt.Sprintf("%v:%v", callback, container.ID[:10])).Inline()
-		buttons = append(buttons, *btn)
+		buttons = append(buttons, tb.InlineButton{
+			Text: container.Names[0][1:],
+			Data: strings.Join(append([]string{callback, container.ID[:10]}, args...), ":"),
+		})
 	}
 	if len(buttons) > 0 {
 		rows = append(rows, buttons)
 	}
-	menu.InlineKeyboard = rows
-	return menu
+	return t.newMenu(user.ID, rows), nil
 }
 
-func (t *Telegram) askForContainer(m *tb.Message, listOps types.ContainerListOptions, cb string) {
-	t.reply(m, "Choose a container", t.makeContainerMenu(listOps, cb))
+func (t *Telegram) askForContainer(m *tb.Message, listOps types.ContainerListOptions, cb string, args ...string) {
+	menu, err := t.makeContainerMenu(m.Chat, m.Sender, listOps, cb, args...)
+	if err != nil {
+		t.replyError(m, err)
+		return
+	}
+	t.reply(m, "Choose a container", menu)
 }
 
 func (t *Telegram) handleLog(c *tb.Callback, payload string) {
-	logs, err := t.dckr.Logs(payload, "10")
+	logs, err := t.dckr.Logs(payload, docker.LogsOptions{Tail: "10"})
 	if err != nil {
 		t.callbackResponse(c, err, payload, "")
 		return
 	}
-	for index, chunk := range logs {
-		if index == 0 {
-			t.callbackResponse(c, err, payload, fmt.Sprintf(FormatedStr, chunk))
-		}
-		if index != 0 && chunk != "" {
-			t.send(c.Message.Chat, fmt.Sprintf(FormatedStr, chunk), tb.ModeHTML)
-		}
-		time.Sleep(100 * time.Millisecond)
-	}
+	t.callbackOutput(c, payload, fmt.Sprintf("%v.log", payload), logsCaption(payload, logs), formatLogs(logs))
 }
 
-func (t *Telegram) callbackResponse(c *tb.Callback, err error, payload interface{}, response string) {
+func (t *Telegram) callbackResponse(c *tb.Callback, err error, payload interface{}, response string, options ...interface{}) {
 	if err != nil {
-		err = t.bot.Respond(c, &tb.CallbackResponse{Text: err.Error(), ShowAlert: false})
-		if err != nil {
-			log.Fatal().Str("module", "utils").Err(err).Msg("error replying to message")
+		t.auditError(callbackKey(c), err)
+		if rErr := t.bot.Respond(c, &tb.CallbackResponse{Text: cause(err), ShowAlert: false}); rErr != nil {
+			log.Error().Err(rErr).Msg("error replying to callback")
 		}
-		_, err = t.bot.Edit(c.Message, fmt.Sprintf("Container %v errored: %v", payload, err.Error()))
-		if err != nil {
-			log.Fatal().Str("module", "utils").Err(err).Msg("error editing message")
+		if _, eErr := t.bot.Edit(c.Message, formatError(err), tb.ModeHTML); eErr != nil {
+			log.Error().Err(eErr).Msg("error editing message")
 		}
 	} else {
 		err := t.bot.Respond(c, &tb.CallbackResponse{Text: "", ShowAlert: false})
 		if err != nil {
-			log.Fatal().Str("module", "utils").Err(err).Msg("error replying to message")
+			log.Error().Err(err).Msg("error replying to callback")
 		}
-		_, err = t.bot.Edit(c.Message, response, tb.ModeHTML)
+		_, err = t.bot.Edit(c.Message, response, append(options, tb.ModeHTML)...)
 		if err != nil {
-			log.Fatal().Str("module", "utils").Err(err).Msg("error editing message")
+			log.Error().Err(err).Msg("error editing message")
 		}
 	}
 }
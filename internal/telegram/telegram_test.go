@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mrmarble/teledock/internal/compose"
+	"github.com/mrmarble/teledock/internal/config"
+	"github.com/mrmarble/teledock/internal/docker"
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestReplyOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    int
+		messages int
+		document bool
+	}{
+		{"one message", 10, 1, false},
+		{"split in messages", 300, 2, false},
+		{"over the threshold", 1000, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := newFakeBot()
+			telegram, err := New(&config.Config{DocumentThreshold: 8000}, bot, docker.NewFake(), compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lines := make([]string, tt.lines)
+			for index := range lines {
+				lines[index] = strings.Repeat("x", 15)
+			}
+			telegram.replyOutput(&tb.Message{Chat: &tb.Chat{ID: 1}}, "out.log", "caption", lines)
+			sent := bot.sent()
+			if len(sent) != tt.messages {
+				t.Fatalf("sent %v messages, want %v", len(sent), tt.messages)
+			}
+			if _, document := sent[0].what.(*tb.Document); document != tt.document {
+				t.Errorf("sent %T, want a document %v", sent[0].what, tt.document)
+			}
+		})
+	}
+}
+
+func TestMakeDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		gzip     bool
+		wantName string
+		wantMIME string
+	}{
+		{"text", "web.log", false, "web.log", "text/plain"},
+		{"json", "web.json", false, "web.json", "application/json"},
+		{"gzip", "web.log", true, "web.log.gz", "application/gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telegram, err := New(&config.Config{DocumentGzip: tt.gzip}, newFakeBot(), docker.NewFake(), compose.NewFake())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			document := telegram.makeDocument(tt.fileName, "caption", []string{"one", "two"})
+			if document.FileName != tt.wantName || document.MIME != tt.wantMIME {
+				t.Errorf("document = %v %v, want %v %v", document.FileName, document.MIME, tt.wantName, tt.wantMIME)
+			}
+			reader := document.FileReader
+			if tt.gzip {
+				if reader, err = gzip.NewReader(reader); err != nil {
+					t.Fatal(err)
+				}
+			}
+			content, err := io.ReadAll(reader)
+			if err != nil || string(content) != "one\ntwo" {
+				t.Errorf("content = %q, %v, want %q", content, err, "one\ntwo")
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"html"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -12,6 +14,8 @@ import (
 	"github.com/mrmarble/teledock/internal/constants"
 )
 
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b[()][A-Z0-9]|\x1b[=>]`)
+
 var state = map[string]emoji.Emoji{
 	"running":    emoji.CheckMarkButton,
 	"created":    emoji.Egg,
@@ -22,6 +26,11 @@ var state = map[string]emoji.Emoji{
 	"dead":       emoji.Skull,
 }
 
+// StateIcon returns the emoji of a container state.
+func StateIcon(containerState string) string {
+	return string(state[containerState])
+}
+
 // parseInt64 parses a string and converts it to int64.
 func ParseInt64(s string) (int64, error) {
 	i, err := strconv.ParseInt(s, 10, 64)
@@ -83,6 +92,53 @@ func FormatComposeList(compose map[string][]types.Container) []string {
 	return resultMsg
 }
 
+// FormatStack formats the containers of a compose stack, one per service.
+func FormatStack(name string, containers []types.Container) []string {
+	sorted := append([]types.Container{}, containers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Labels[constants.ComposeServiceLabel] < sorted[j].Labels[constants.ComposeServiceLabel]
+	})
+	resultMsg := []string{fmt.Sprintf("<b>%v</b>", html.EscapeString(name))}
+	for _, container := range sorted {
+		resultMsg = append(resultMsg, strings.Join([]string{
+			fmt.Sprintf("%v  <b>%v</b>", state[container.State], html.EscapeString(container.Labels[constants.ComposeServiceLabel])),
+			fmt.Sprintf(constants.FormatedStrPadded, "NAME:", html.EscapeString(container.Names[0][1:])),
+			fmt.Sprintf(constants.FormatedStrPadded, "ID:", container.ID[:12]),
+			fmt.Sprintf(constants.FormatedStrPadded, "STATUS:", container.Status),
+		}, "\n"))
+	}
+	return resultMsg
+}
+
+// FormatBytes formats a size with binary units, like 1.5MiB.
+func FormatBytes(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%vB", size)
+	}
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// FormatExecResult joins the output streams of a command, stderr last.
+func FormatExecResult(stdout, stderr string) string {
+	output := stdout
+	if stderr != "" {
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		output += "--- stderr ---\n" + stderr
+	}
+	if output == "" {
+		return "(no output)"
+	}
+	return output
+}
+
 func FormatStruct(data interface{}) (string, error) {
 	result, err := json.MarshalIndent(data, "", " ")
 	if err != nil {
@@ -108,3 +164,74 @@ func ChunkString(s string, chunkSize int) []string {
 	}
 	return chunks
 }
+
+// SplitArgs splits s into words like a shell would, honouring single and double quotes.
+func SplitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inWord  bool
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// StripANSI removes terminal escape sequences and carriage returns from s.
+func StripANSI(s string) string {
+	return strings.ReplaceAll(ansiRe.ReplaceAllString(s, ""), "\r", "")
+}
+
+// ChunkLines joins lines into chunks of at most chunkSize runes, splitting only
+// on line boundaries unless a single line does not fit.
+func ChunkLines(lines []string, chunkSize int) []string {
+	var (
+		chunks  []string
+		current []rune
+	)
+	for _, line := range lines {
+		runes := []rune(line)
+		if len(current) > 0 && len(current)+1+len(runes) > chunkSize {
+			chunks = append(chunks, string(current))
+			current = nil
+		}
+		if len(runes) > chunkSize {
+			parts := ChunkString(line, chunkSize)
+			chunks = append(chunks, parts[:len(parts)-1]...)
+			runes = []rune(parts[len(parts)-1])
+		}
+		if len(current) > 0 {
+			current = append(current, '\n')
+		}
+		current = append(current, runes...)
+	}
+	if len(current) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, string(current))
+	}
+	return chunks
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		size  int
+		want  []string
+	}{
+		{"empty", nil, 10, []string{""}},
+		{"fits", []string{"ab", "cd"}, 10, []string{"ab\ncd"}},
+		{"exact", []string{"abcd", "efgh"}, 9, []string{"abcd\nefgh"}},
+		{"splits on lines", []string{"abcd", "efgh", "ij"}, 8, []string{"abcd", "efgh\nij"}},
+		{"long line", []string{"ab", "cdefghij", "k"}, 4, []string{"ab", "cdef", "ghij", "k"}},
+		{"runes", []string{"ñññ", "ñ"}, 4, []string{"ñññ", "ñ"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChunkLines(tt.lines, tt.size)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChunkLines(%q, %v) = %q, want %q", tt.lines, tt.size, got, tt.want)
+			}
+			for _, chunk := range got {
+				if size := len([]rune(chunk)); size > tt.size {
+					t.Errorf("chunk %q has %v runes, over %v", chunk, size, tt.size)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{"", nil, ""},
+		{"ls -la", []string{"ls", "-la"}, ""},
+		{"  spaced \t out\n", []string{"spaced", "out"}, ""},
+		{`sh -c "echo hi there"`, []string{"sh", "-c", "echo hi there"}, ""},
+		{`echo 'it"s'`, []string{"echo", `it"s`}, ""},
+		{`a""b ''`, []string{"ab", ""}, ""},
+		{`echo "open`, nil, `unterminated " quote`},
+		{`echo 'open`, nil, "unterminated ' quote"},
+	}
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.input)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("SplitArgs(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SplitArgs(%q) error = %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}